@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Defaults of the analyze command.
+const (
+	defaultFleetTop          = 10
+	defaultFleetContributors = 3
+)
+
+// FleetReport aggregates the summaries of many scrapes, e.g. one per service.
+// Its totals only include the targets that were analyzed, not those that
+// failed.
+type FleetReport struct {
+	Targets  []FleetTarget `json:"targets"`
+	Bytes    int64         `json:"bytes"`
+	Series   int           `json:"series"`
+	Families int           `json:"families"`
+	// TopFamilies are the families with the most series across all targets.
+	TopFamilies []FleetFamily `json:"top_families"`
+	// SharedLabels are the labels used by more than one target.
+	SharedLabels []FleetLabel `json:"shared_labels"`
+}
+
+// FleetTarget is the summary of one scrape file. Error is set if the file
+// could not be read or parsed.
+type FleetTarget struct {
+	Name     string `json:"name"`
+	Bytes    int64  `json:"bytes"`
+	Series   int    `json:"series"`
+	Families int    `json:"families"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FleetFamily is a metric family aggregated across targets. TopTargets are the
+// targets contributing the most series.
+type FleetFamily struct {
+	Name       string              `json:"name"`
+	Type       string              `json:"type"`
+	Series     int                 `json:"series"`
+	Bytes      int64               `json:"bytes"`
+	Targets    int                 `json:"targets"`
+	TopTargets []FleetContribution `json:"top_targets"`
+}
+
+// FleetContribution is the share of a family's series exposed by a target.
+type FleetContribution struct {
+	Target string  `json:"target"`
+	Series int     `json:"series"`
+	Share  float64 `json:"share"`
+}
+
+// FleetLabel counts the targets and metric families using a label.
+type FleetLabel struct {
+	Name     string `json:"name"`
+	Targets  int    `json:"targets"`
+	Families int    `json:"families"`
+}
+
+// scrapeFile is the summary of one scrape file.
+type scrapeFile struct {
+	Name    string
+	Summary ScrapeSummary
+	Err     error
+}
+
+// expandScrapePaths resolves files, directories (all regular files within,
+// recursively, skipping hidden entries) and glob patterns into a sorted list
+// of files without duplicates.
+func expandScrapePaths(args []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(m)
+				continue
+			}
+			err = filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if path != m && strings.HasPrefix(d.Name(), ".") {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if d.Type().IsRegular() {
+					add(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// summarizeFiles summarizes the given scrape files concurrently with at most
+// workers files being processed at a time. Results are in the order of paths.
+func summarizeFiles(paths []string, workers int) []scrapeFile {
+	results := make([]scrapeFile, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = summarizeFile(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func summarizeFile(path string) scrapeFile {
+	f := scrapeFile{Name: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		f.Err = err
+		return f
+	}
+	f.Summary, f.Err = summarizeCompressed(context.Background(), data)
+	return f
+}
+
+// summarizeChecked summarizes a decompressed scrape like
+// summarizeScrapeContext but also reports the parse error SummarizeScrape
+// hides behind an empty summary.
+func summarizeChecked(ctx context.Context, data []byte) (ScrapeSummary, error) {
+	s, err := summarizeScrapeContext(ctx, data)
+	if err != nil {
+		return s, err
+	}
+	if len(s.Metrics) == 0 && len(data) > 0 {
+		if _, err := decodeFamilies(data); err != nil {
+			return s, fmt.Errorf("parsing: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// BuildFleetReport aggregates per-file summaries into a fleet report with the
+// top families by series (at most top) and the labels shared across targets.
+func BuildFleetReport(files []scrapeFile, top int) FleetReport {
+	var r FleetReport
+
+	families := make(map[string]*FleetFamily)
+	contributions := make(map[string][]FleetContribution)
+	type labelUse struct{ targets, families int }
+	labels := make(map[string]*labelUse)
+
+	for _, f := range files {
+		t := FleetTarget{Name: f.Name, Bytes: f.Summary.Summary.Bytes, Families: len(f.Summary.Metrics)}
+		if f.Err != nil {
+			t.Error = f.Err.Error()
+		}
+		for _, m := range f.Summary.Metrics {
+			t.Series += m.Cardinality
+
+			ff, ok := families[m.Name]
+			if !ok {
+				ff = &FleetFamily{Name: m.Name, Type: strings.ToLower(m.Type)}
+				families[m.Name] = ff
+			}
+			ff.Series += m.Cardinality
+			ff.Bytes += m.Size
+			ff.Targets++
+			contributions[m.Name] = append(contributions[m.Name], FleetContribution{Target: f.Name, Series: m.Cardinality})
+		}
+		for l, n := range f.Summary.Summary.LabelCounts {
+			if l == noneLabelKey || n == 0 {
+				continue
+			}
+			u, ok := labels[l]
+			if !ok {
+				u = &labelUse{}
+				labels[l] = u
+			}
+			u.targets++
+			u.families += n
+		}
+
+		r.Targets = append(r.Targets, t)
+		if f.Err == nil {
+			r.Bytes += t.Bytes
+		}
+		r.Series += t.Series
+	}
+	r.Families = len(families)
+
+	sort.SliceStable(r.Targets, func(i, j int) bool {
+		return r.Targets[i].Series > r.Targets[j].Series
+	})
+
+	all := make([]*FleetFamily, 0, len(families))
+	for _, ff := range families {
+		all = append(all, ff)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Series == all[j].Series {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].Series > all[j].Series
+	})
+	for _, ff := range all[:min(top, len(all))] {
+		cs := contributions[ff.Name]
+		sort.SliceStable(cs, func(i, j int) bool {
+			return cs[i].Series > cs[j].Series
+		})
+		for _, c := range cs[:min(defaultFleetContributors, len(cs))] {
+			if ff.Series > 0 {
+				c.Share = float64(c.Series) / float64(ff.Series)
+			}
+			ff.TopTargets = append(ff.TopTargets, c)
+		}
+		r.TopFamilies = append(r.TopFamilies, *ff)
+	}
+
+	for name, u := range labels {
+		if u.targets > 1 {
+			r.SharedLabels = append(r.SharedLabels, FleetLabel{Name: name, Targets: u.targets, Families: u.families})
+		}
+	}
+	sort.Slice(r.SharedLabels, func(i, j int) bool {
+		if r.SharedLabels[i].Targets == r.SharedLabels[j].Targets {
+			return r.SharedLabels[i].Name < r.SharedLabels[j].Name
+		}
+		return r.SharedLabels[i].Targets > r.SharedLabels[j].Targets
+	})
+	return r
+}
+
+// runAnalyze implements `scrapecli analyze [flags] PATH...` where each path is
+// a scrape file, a directory of scrape files or a glob pattern.
+func runAnalyze(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	var outputFormat string
+	fs.StringVar(&outputFormat, "output-format", "terminal", "Output format: terminal or json")
+	fs.StringVar(&outputFormat, "o", "terminal", "Shorthand for --output-format")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of files summarized concurrently")
+	top := fs.Int("top", defaultFleetTop, "Number of top families to report")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: scrapecli analyze [flags] DIR|FILE|GLOB...")
+	}
+	if *top < 1 {
+		return fmt.Errorf("--top must be positive")
+	}
+
+	paths, err := expandScrapePaths(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no scrape files found")
+	}
+	r := BuildFleetReport(summarizeFiles(paths, *workers), *top)
+
+	switch strings.ToLower(outputFormat) {
+	case "json":
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	default:
+		_, err := io.WriteString(out, FormatFleetReportTerminal(r))
+		return err
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandScrapePaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.prom", ".hidden", "sub/c.txt", ".git/d.txt"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("up 1\n"), 0o644))
+	}
+
+	paths, err := expandScrapePaths([]string{dir})
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.prom"), filepath.Join(dir, "sub/c.txt")}, paths)
+
+	// Globs and files are combined without duplicates
+	paths, err = expandScrapePaths([]string{filepath.Join(dir, "*.txt"), filepath.Join(dir, "a.txt")})
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "a.txt")}, paths)
+
+	_, err = expandScrapePaths([]string{filepath.Join(dir, "*.json")})
+	require.Error(t, err)
+}
+
+func TestBuildFleetReport(t *testing.T) {
+	files := []scrapeFile{
+		{Name: "api", Summary: SummarizeScrape([]byte("# TYPE http_requests_total counter\nhttp_requests_total{code=\"200\",instance=\"a\"} 1\nhttp_requests_total{code=\"500\",instance=\"a\"} 1\nhttp_requests_total{code=\"503\",instance=\"a\"} 1\n# TYPE up gauge\nup 1\n"))},
+		{Name: "web", Summary: SummarizeScrape([]byte("# TYPE http_requests_total counter\nhttp_requests_total{code=\"200\",instance=\"b\"} 1\n# TYPE queue_length gauge\nqueue_length{instance=\"b\"} 1\n"))},
+		{Name: "broken", Summary: SummarizeScrape([]byte("garbage{\n")), Err: os.ErrInvalid},
+	}
+
+	r := BuildFleetReport(files, 2)
+	require.Equal(t, files[0].Summary.Summary.Bytes+files[1].Summary.Summary.Bytes, r.Bytes, "failed targets are not counted")
+	require.Positive(t, files[2].Summary.Summary.Bytes)
+	require.Equal(t, 6, r.Series)
+	require.Equal(t, 3, r.Families)
+	require.Equal(t, []string{"api", "web", "broken"}, []string{r.Targets[0].Name, r.Targets[1].Name, r.Targets[2].Name})
+	require.Equal(t, os.ErrInvalid.Error(), r.Targets[2].Error)
+
+	require.Len(t, r.TopFamilies, 2)
+	require.Equal(t, "http_requests_total", r.TopFamilies[0].Name)
+	require.Equal(t, 4, r.TopFamilies[0].Series)
+	require.Equal(t, 2, r.TopFamilies[0].Targets)
+	require.Equal(t, []FleetContribution{{Target: "api", Series: 3, Share: 0.75}, {Target: "web", Series: 1, Share: 0.25}}, r.TopFamilies[0].TopTargets)
+
+	// Both labels are used by both targets; instance by one family of api and two of web
+	require.Equal(t, []FleetLabel{{Name: "code", Targets: 2, Families: 2}, {Name: "instance", Targets: 2, Families: 3}}, r.SharedLabels)
+}
+
+func TestRunAnalyze(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("up 1\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("up 1\n"), 0o644))
+
+	var out bytes.Buffer
+	require.NoError(t, runAnalyze([]string{"-o", "json", "--workers", "2", dir}, &out))
+	var r FleetReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &r))
+	require.Len(t, r.Targets, 2)
+	require.Equal(t, 2, r.Series)
+	require.Equal(t, 1, r.Families)
+
+	for _, top := range []string{"0", "-1"} {
+		err := runAnalyze([]string{"--top", top, dir}, &out)
+		require.EqualError(t, err, "--top must be positive")
+	}
+}
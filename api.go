@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/munnerz/goautoneg"
+)
+
+// apiVersion is the version of the request and response schema of the HTTP
+// API. It changes whenever a response changes incompatibly.
+const apiVersion = "v1"
+
+// Defaults of the serve-api command.
+const (
+	defaultAPIListen         = ":9798"
+	defaultAPIMaxBodyBytes   = 10 << 20
+	defaultAPIMaxScrapeBytes = 100 << 20
+	defaultAPIRequestTimeout = 30 * time.Second
+)
+
+// Content types of API responses and of the diff request, whose parts are
+// the old and new scrape.
+const (
+	apiContentTypeJSON      = "application/json"
+	apiContentTypeMarkdown  = "text/markdown"
+	apiContentTypeHTML      = "text/html"
+	apiContentTypeMultipart = "multipart/form-data"
+	apiMultipartOld         = "old"
+	apiMultipartNew         = "new"
+)
+
+// apiInputTypes are the content types accepted for scrapes. The format is
+// detected from the body, so they are only checked to reject other uploads.
+// Form encoding is what `curl --data-binary` sends by default.
+var apiInputTypes = map[string]bool{
+	"":                                  true,
+	"text/plain":                        true,
+	"application/openmetrics-text":      true,
+	"application/vnd.google.protobuf":   true,
+	"application/octet-stream":          true,
+	"application/x-www-form-urlencoded": true,
+}
+
+// apiInputEncodings are the accepted Content-Encodings of scrapes.
+var apiInputEncodings = map[string]bool{
+	"":             true,
+	"identity":     true,
+	encodingGzip:   true,
+	encodingZstd:   true,
+	encodingSnappy: true,
+}
+
+// AnalyzeResponse is the JSON response of POST /analyze.
+type AnalyzeResponse struct {
+	APIVersion string        `json:"api_version"`
+	Summary    ScrapeSummary `json:"summary"`
+}
+
+// DiffResponse is the JSON response of POST /diff.
+type DiffResponse struct {
+	APIVersion string     `json:"api_version"`
+	Diff       ScrapeDiff `json:"diff"`
+}
+
+// APIError is the JSON response of failed requests.
+type APIError struct {
+	APIVersion string `json:"api_version"`
+	Error      string `json:"error"`
+}
+
+// APIOptions limit the resources used by requests to the API.
+type APIOptions struct {
+	// MaxBodyBytes limits the size of request bodies as sent, i.e. possibly
+	// compressed. MaxScrapeBytes limits the size of a decompressed scrape.
+	MaxBodyBytes   int64
+	MaxScrapeBytes int64
+	// Timeout bounds the time to read, analyze and answer a request.
+	Timeout time.Duration
+	// MaxConcurrent bounds the number of requests analyzed at the same time.
+	MaxConcurrent int
+}
+
+// apiServer implements the HTTP analysis API. Analyses share no state, so
+// requests are handled concurrently, up to the configured bound.
+type apiServer struct {
+	opts APIOptions
+	sem  chan struct{}
+}
+
+// newAPIHandler returns the handler of the HTTP API. Endpoints are served
+// both with and without the version prefix, e.g. /v1/analyze and /analyze.
+func newAPIHandler(opts APIOptions) http.Handler {
+	s := &apiServer{opts: opts, sem: make(chan struct{}, max(opts.MaxConcurrent, 1))}
+	mux := http.NewServeMux()
+	for _, prefix := range []string{"", "/" + apiVersion} {
+		mux.HandleFunc(prefix+"/analyze", s.handleAnalyze)
+		mux.HandleFunc(prefix+"/diff", s.handleDiff)
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	return http.TimeoutHandler(mux, opts.Timeout, `{"api_version":"`+apiVersion+`","error":"request timed out"}`)
+}
+
+// apiStatusError is an error with the HTTP status to answer it with.
+type apiStatusError struct {
+	status int
+	err    error
+}
+
+func (e *apiStatusError) Error() string { return e.err.Error() }
+
+func statusErrorf(status int, format string, args ...any) error {
+	return &apiStatusError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func (s *apiServer) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	contentType, err := s.checkRequest(w, r, apiContentTypeJSON, apiContentTypeMarkdown, apiContentTypeHTML)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if err := checkScrapeHeaders(r.Header); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	selector, err := apiSelector(r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	data, err := readAPIBody(r.Body)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	release, err := s.acquire(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer release()
+	summary, err := s.summarize(r.Context(), data, selector)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	switch contentType {
+	case apiContentTypeMarkdown:
+		_, _ = io.WriteString(w, FormatScrapeSummaryMarkdown(summary))
+	case apiContentTypeHTML:
+		_ = FormatScrapeSummaryHTML(w, summary, "Prometheus scrape report")
+	default:
+		_ = json.NewEncoder(w).Encode(AnalyzeResponse{APIVersion: apiVersion, Summary: summary})
+	}
+}
+
+// handleDiff compares the old and new scrapes, sent as the parts of a
+// multipart/form-data body.
+func (s *apiServer) handleDiff(w http.ResponseWriter, r *http.Request) {
+	contentType, err := s.checkRequest(w, r, apiContentTypeJSON, apiContentTypeMarkdown)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != apiContentTypeMultipart {
+		writeAPIError(w, statusErrorf(http.StatusUnsupportedMediaType, "expected a %s body with %q and %q parts", apiContentTypeMultipart, apiMultipartOld, apiMultipartNew))
+		return
+	}
+	selector, err := apiSelector(r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeAPIError(w, statusErrorf(http.StatusBadRequest, "reading multipart body: %v", err))
+		return
+	}
+	parts := make(map[string][]byte)
+	for {
+		p, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writeAPIError(w, bodyError(err))
+			return
+		}
+		name := p.FormName()
+		if name != apiMultipartOld && name != apiMultipartNew {
+			continue
+		}
+		if err := checkScrapeHeaders(http.Header(p.Header)); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if parts[name], err = readAPIBody(p); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+	}
+	for _, name := range []string{apiMultipartOld, apiMultipartNew} {
+		if _, ok := parts[name]; !ok {
+			writeAPIError(w, statusErrorf(http.StatusBadRequest, "missing %q part", name))
+			return
+		}
+	}
+
+	release, err := s.acquire(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer release()
+	old, err := s.summarize(r.Context(), parts[apiMultipartOld], selector)
+	if err != nil {
+		writeAPIError(w, fmt.Errorf("%s: %w", apiMultipartOld, err))
+		return
+	}
+	cur, err := s.summarize(r.Context(), parts[apiMultipartNew], selector)
+	if err != nil {
+		writeAPIError(w, fmt.Errorf("%s: %w", apiMultipartNew, err))
+		return
+	}
+	d := DiffScrapeSummaries(old, cur)
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	switch contentType {
+	case apiContentTypeMarkdown:
+		_, _ = io.WriteString(w, FormatScrapeDiffMarkdown(d))
+	default:
+		_ = json.NewEncoder(w).Encode(DiffResponse{APIVersion: apiVersion, Diff: d})
+	}
+}
+
+// checkRequest checks the method, limits the body size and negotiates the
+// response content type among offers, the first being the default.
+func (s *apiServer) checkRequest(w http.ResponseWriter, r *http.Request, offers ...string) (string, error) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		return "", statusErrorf(http.StatusMethodNotAllowed, "method %s not allowed, use POST", r.Method)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes)
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0], nil
+	}
+	contentType := goautoneg.Negotiate(accept, offers)
+	if contentType == "" {
+		return "", statusErrorf(http.StatusNotAcceptable, "cannot produce %q, supported: %s", accept, strings.Join(offers, ", "))
+	}
+	return contentType, nil
+}
+
+// checkScrapeHeaders rejects scrapes declared in an unsupported content type
+// or encoding.
+func checkScrapeHeaders(h http.Header) error {
+	mediaType := ""
+	if ct := h.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return statusErrorf(http.StatusUnsupportedMediaType, "invalid Content-Type %q", ct)
+		}
+	}
+	if !apiInputTypes[mediaType] {
+		return statusErrorf(http.StatusUnsupportedMediaType, "unsupported Content-Type %q", mediaType)
+	}
+	if encoding := strings.ToLower(h.Get("Content-Encoding")); !apiInputEncodings[encoding] {
+		return statusErrorf(http.StatusUnsupportedMediaType, "unsupported Content-Encoding %q", encoding)
+	}
+	return nil
+}
+
+// apiSelector parses the optional select query parameter.
+func apiSelector(r *http.Request) (Selector, error) {
+	expr := r.URL.Query().Get("select")
+	if expr == "" {
+		return nil, nil
+	}
+	selector, err := ParseSelector(expr)
+	if err != nil {
+		return nil, statusErrorf(http.StatusBadRequest, "invalid select: %v", err)
+	}
+	return selector, nil
+}
+
+func readAPIBody(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return data, nil
+}
+
+// bodyError maps errors reading a request body to a status.
+func bodyError(err error) error {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return statusErrorf(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", maxBytes.Limit)
+	}
+	return statusErrorf(http.StatusBadRequest, "reading request body: %v", err)
+}
+
+// acquire waits for an analysis slot. It fails if the request is canceled
+// or times out while waiting.
+func (s *apiServer) acquire(ctx context.Context) (func(), error) {
+	select {
+	case s.sem <- struct{}{}:
+		return func() { <-s.sem }, nil
+	case <-ctx.Done():
+		return nil, statusErrorf(http.StatusServiceUnavailable, "too many concurrent requests")
+	}
+}
+
+// summarize decompresses, selects and summarizes a scrape. It gives up once
+// ctx is done, e.g. because the request timed out.
+func (s *apiServer) summarize(ctx context.Context, data []byte, selector Selector) (ScrapeSummary, error) {
+	raw := data
+	data, encoding, err := decompressScrapeLimit(raw, s.opts.MaxScrapeBytes)
+	if errors.Is(err, errScrapeTooLarge) {
+		return ScrapeSummary{}, statusErrorf(http.StatusRequestEntityTooLarge, "scrape exceeds %d bytes", s.opts.MaxScrapeBytes)
+	}
+	if err != nil {
+		return ScrapeSummary{}, statusErrorf(http.StatusBadRequest, "%v", err)
+	}
+	decompressedBytes := int64(len(data))
+	if selector != nil {
+		if data, err = SelectScrape(data, selector); err != nil {
+			return ScrapeSummary{}, statusErrorf(http.StatusUnprocessableEntity, "selecting series: %v", err)
+		}
+	}
+	summary, err := summarizeChecked(ctx, data)
+	if ctx.Err() != nil {
+		return ScrapeSummary{}, statusErrorf(http.StatusServiceUnavailable, "analysis canceled: %v", ctx.Err())
+	}
+	if err != nil {
+		return ScrapeSummary{}, statusErrorf(http.StatusUnprocessableEntity, "%v", err)
+	}
+	if encoding != "" {
+		summary.Summary.setCompression(encoding, int64(len(raw)), decompressedBytes)
+	}
+	return summary, nil
+}
+
+// writeAPIError answers with the status of err (500 if it has none) and a
+// JSON error body.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var se *apiStatusError
+	if errors.As(err, &se) {
+		status = se.status
+	}
+	w.Header().Set("Content-Type", apiContentTypeJSON+"; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(APIError{APIVersion: apiVersion, Error: err.Error()})
+}
+
+// runServeAPI implements `scrapecli serve-api`.
+func runServeAPI(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("serve-api", flag.ContinueOnError)
+	listen := fs.String("listen", defaultAPIListen, "Address to serve the API on")
+	var opts APIOptions
+	fs.Int64Var(&opts.MaxBodyBytes, "max-body-bytes", defaultAPIMaxBodyBytes, "Maximum size of a request body")
+	fs.Int64Var(&opts.MaxScrapeBytes, "max-scrape-bytes", defaultAPIMaxScrapeBytes, "Maximum size of a decompressed scrape")
+	fs.DurationVar(&opts.Timeout, "timeout", defaultAPIRequestTimeout, "Maximum time to handle a request")
+	fs.IntVar(&opts.MaxConcurrent, "max-concurrent", runtime.NumCPU(), "Maximum number of requests analyzed concurrently")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: scrapecli serve-api [flags]")
+	}
+	if opts.MaxBodyBytes <= 0 || opts.MaxScrapeBytes <= 0 || opts.Timeout <= 0 {
+		return fmt.Errorf("--max-body-bytes, --max-scrape-bytes and --timeout must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           newAPIHandler(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       opts.Timeout,
+		// Responses are written after the handler timeout at the latest
+		WriteTimeout: opts.Timeout + 5*time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(out, "Serving API %s on %s\n", apiVersion, *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	apiTestOld = "# TYPE http_requests_total counter\nhttp_requests_total{code=\"200\"} 1\n# TYPE up gauge\nup 1\n"
+	apiTestNew = "# TYPE http_requests_total counter\nhttp_requests_total{code=\"200\"} 1\nhttp_requests_total{code=\"500\"} 1\n"
+)
+
+func testAPIOptions() APIOptions {
+	return APIOptions{MaxBodyBytes: 1 << 20, MaxScrapeBytes: 1 << 20, Timeout: 5 * time.Second, MaxConcurrent: 2}
+}
+
+func TestAPIAnalyze(t *testing.T) {
+	srv := httptest.NewServer(newAPIHandler(testAPIOptions()))
+	defer srv.Close()
+
+	for _, path := range []string{"/analyze", "/v1/analyze"} {
+		resp, err := http.Post(srv.URL+path, "text/plain; version=0.0.4", strings.NewReader(apiTestOld))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+		var r AnalyzeResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+		resp.Body.Close()
+		require.Equal(t, apiVersion, r.APIVersion)
+		require.Len(t, r.Summary.Metrics, 2)
+		require.Equal(t, int64(len(apiTestOld)), r.Summary.Summary.Bytes)
+	}
+
+	// Compressed bodies are decompressed
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(apiTestOld))
+	require.NoError(t, zw.Close())
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/analyze?select=up", &gz)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var r AnalyzeResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+	resp.Body.Close()
+	require.Equal(t, encodingGzip, r.Summary.Summary.Encoding)
+	require.Len(t, r.Summary.Metrics, 1)
+	// The ratio describes the whole scrape, not the selection
+	require.Less(t, r.Summary.Summary.Bytes, int64(len(apiTestOld)))
+	require.InDelta(t, float64(len(apiTestOld))/float64(r.Summary.Summary.CompressedBytes), r.Summary.Summary.CompressionRatio, 1e-9)
+
+	// Markdown is negotiated with the Accept header
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/analyze", strings.NewReader(apiTestOld))
+	req.Header.Set("Accept", "text/markdown, application/json;q=0.5")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/markdown; charset=utf-8", resp.Header.Get("Content-Type"))
+}
+
+func TestAPIErrors(t *testing.T) {
+	opts := testAPIOptions()
+	opts.MaxBodyBytes = 64
+	srv := httptest.NewServer(newAPIHandler(opts))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		body    string
+		status  int
+	}{
+		{"wrong method", http.MethodGet, "/analyze", nil, "", http.StatusMethodNotAllowed},
+		{"not acceptable", http.MethodPost, "/analyze", map[string]string{"Accept": "image/png"}, "up 1\n", http.StatusNotAcceptable},
+		{"unsupported content type", http.MethodPost, "/analyze", map[string]string{"Content-Type": "image/png"}, "up 1\n", http.StatusUnsupportedMediaType},
+		{"unsupported encoding", http.MethodPost, "/analyze", map[string]string{"Content-Encoding": "br"}, "up 1\n", http.StatusUnsupportedMediaType},
+		{"too large", http.MethodPost, "/analyze", nil, strings.Repeat("up 1\n", 20), http.StatusRequestEntityTooLarge},
+		{"unparsable", http.MethodPost, "/analyze", nil, "up{ 1\n", http.StatusUnprocessableEntity},
+		{"invalid select", http.MethodPost, "/analyze?select=%7B", nil, "up 1\n", http.StatusBadRequest},
+		{"diff without multipart", http.MethodPost, "/diff", nil, "up 1\n", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+			var e APIError
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+			require.Equal(t, apiVersion, e.APIVersion)
+			require.NotEmpty(t, e.Error)
+		})
+	}
+}
+
+func TestAPIDecompressedSizeLimit(t *testing.T) {
+	opts := testAPIOptions()
+	opts.MaxScrapeBytes = 100
+	srv := httptest.NewServer(newAPIHandler(opts))
+	defer srv.Close()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(strings.Repeat("up 1\n", 100)))
+	require.NoError(t, zw.Close())
+	resp, err := http.Post(srv.URL+"/analyze", "", &gz)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// A body far below the body limit that inflates to 256 MiB, as 256 gzip
+	// members of 1 MiB of zeros each, is rejected without inflating it
+	var member bytes.Buffer
+	zw, err = gzip.NewWriterLevel(&member, gzip.BestCompression)
+	require.NoError(t, err)
+	_, _ = zw.Write(make([]byte, 1<<20))
+	require.NoError(t, zw.Close())
+	gz.Reset()
+	for i := 0; i < 256; i++ {
+		gz.Write(member.Bytes())
+	}
+	require.Less(t, int64(gz.Len()), opts.MaxBodyBytes)
+	resp, err = http.Post(srv.URL+"/analyze", "", &gz)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func diffRequestBody(t *testing.T, parts map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range parts {
+		w, err := mw.CreateFormFile(name, name+".txt")
+		require.NoError(t, err)
+		_, _ = w.Write([]byte(content))
+	}
+	require.NoError(t, mw.Close())
+	return &body, mw.FormDataContentType()
+}
+
+func TestAPIDiff(t *testing.T) {
+	srv := httptest.NewServer(newAPIHandler(testAPIOptions()))
+	defer srv.Close()
+
+	body, contentType := diffRequestBody(t, map[string]string{"old": apiTestOld, "new": apiTestNew})
+	resp, err := http.Post(srv.URL+"/v1/diff", contentType, body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var r DiffResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+	resp.Body.Close()
+	require.Equal(t, apiVersion, r.APIVersion)
+	require.Equal(t, DiffScrapeSummaries(SummarizeScrape([]byte(apiTestOld)), SummarizeScrape([]byte(apiTestNew))), r.Diff)
+
+	body, contentType = diffRequestBody(t, map[string]string{"old": apiTestOld})
+	resp, err = http.Post(srv.URL+"/diff", contentType, body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAPIConcurrentRequests(t *testing.T) {
+	srv := httptest.NewServer(newAPIHandler(testAPIOptions()))
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 16)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(srv.URL+"/analyze", "text/plain", strings.NewReader(apiTestNew))
+			if err != nil {
+				return
+			}
+			statuses[i] = resp.StatusCode
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	for _, status := range statuses {
+		require.Equal(t, http.StatusOK, status)
+	}
+}
+
+func TestAPIAcquire(t *testing.T) {
+	s := &apiServer{opts: testAPIOptions(), sem: make(chan struct{}, 1)}
+	release, err := s.acquire(t.Context())
+	require.NoError(t, err)
+
+	// Requests wait for a free slot until their context is done
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.acquire(ctx)
+	var se *apiStatusError
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, http.StatusServiceUnavailable, se.status)
+
+	release()
+	release, err = s.acquire(t.Context())
+	require.NoError(t, err)
+	release()
+}
+
+func TestAPISummarizeCanceled(t *testing.T) {
+	s := &apiServer{opts: testAPIOptions(), sem: make(chan struct{}, 1)}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := s.summarize(ctx, []byte(apiTestOld), nil)
+	var se *apiStatusError
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, http.StatusServiceUnavailable, se.status)
+
+	summary, err := s.summarize(t.Context(), []byte(apiTestOld), nil)
+	require.NoError(t, err)
+	require.Len(t, summary.Metrics, 2)
+}
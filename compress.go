@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats detected by decompressScrape.
+const (
+	encodingGzip   = "gzip"
+	encodingZstd   = "zstd"
+	encodingSnappy = "snappy"
+)
+
+// Magic bytes at the start of compressed data.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	// Stream identifier chunks of framed snappy and its S2 extension.
+	snappyFramedMagic = []byte("\xff\x06\x00\x00sNaPpY")
+	s2FramedMagic     = []byte("\xff\x06\x00\x00S2sTwO")
+)
+
+// errScrapeTooLarge is returned by decompressScrapeLimit for scrapes larger
+// than the limit.
+var errScrapeTooLarge = errors.New("scrape exceeds the size limit")
+
+// decompressScrape detects gzip, zstd and (framed) snappy compressed data by
+// its magic bytes and returns the decompressed data along with the detected
+// encoding. Data that isn't compressed is returned as is with an empty
+// encoding. Raw snappy blocks have no magic bytes; they are only assumed if
+// data can't be a text or protobuf scrape and decodes to one.
+func decompressScrape(data []byte) ([]byte, string, error) {
+	return decompressScrapeLimit(data, -1)
+}
+
+// decompressScrapeLimit is decompressScrape for untrusted input: it fails
+// with errScrapeTooLarge as soon as the decompressed scrape exceeds limit
+// bytes, so a small body can't expand to an arbitrary size. A negative limit
+// disables the check.
+func decompressScrapeLimit(data []byte, limit int64) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, encodingGzip, fmt.Errorf("decompressing gzip: %w", err)
+		}
+		out, err := readLimit(r, limit)
+		if err != nil {
+			return nil, encodingGzip, fmt.Errorf("decompressing gzip: %w", err)
+		}
+		return out, encodingGzip, nil
+	case bytes.HasPrefix(data, zstdMagic):
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if limit >= 0 {
+			// Streams are decoded with windows of at most this size, which
+			// allows the 8 MiB encoders use by default even for small limits
+			opts = append(opts, zstd.WithDecoderMaxMemory(uint64(max(limit, 8<<20))+1))
+		}
+		d, err := zstd.NewReader(bytes.NewReader(data), opts...)
+		if err != nil {
+			return nil, encodingZstd, err
+		}
+		defer d.Close()
+		out, err := readLimit(d, limit)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			err = errScrapeTooLarge
+		}
+		if err != nil {
+			return nil, encodingZstd, fmt.Errorf("decompressing zstd: %w", err)
+		}
+		return out, encodingZstd, nil
+	case bytes.HasPrefix(data, snappyFramedMagic), bytes.HasPrefix(data, s2FramedMagic):
+		out, err := readLimit(s2.NewReader(bytes.NewReader(data)), limit)
+		if err != nil {
+			return nil, encodingSnappy, fmt.Errorf("decompressing snappy: %w", err)
+		}
+		return out, encodingSnappy, nil
+	}
+
+	if len(data) > 0 && !looksLikeScrape(data) {
+		// Raw blocks start with their decoded length
+		if n, err := s2.DecodedLen(data); err == nil {
+			if limit >= 0 && int64(n) > limit {
+				return nil, encodingSnappy, errScrapeTooLarge
+			}
+			if out, err := s2.Decode(nil, data); err == nil && looksLikeScrape(out) {
+				return out, encodingSnappy, nil
+			}
+		}
+	}
+	if limit >= 0 && int64(len(data)) > limit {
+		return nil, "", errScrapeTooLarge
+	}
+	return data, "", nil
+}
+
+// readLimit reads r to the end. It fails with errScrapeTooLarge once more than
+// limit bytes are read, unless limit is negative.
+func readLimit(r io.Reader, limit int64) ([]byte, error) {
+	if limit < 0 {
+		return io.ReadAll(r)
+	}
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, errScrapeTooLarge
+	}
+	return out, nil
+}
+
+// looksLikeScrape reports whether data starts like a text scrape (a comment,
+// blank or metric name) or a delimited protobuf scrape.
+func looksLikeScrape(data []byte) bool {
+	if len(data) == 0 || textScrapeStart(data[0]) {
+		return true
+	}
+	_, ok := decodeProtobuf(data)
+	return ok
+}
+
+// textScrapeStart reports whether a text scrape can start with c.
+func textScrapeStart(c byte) bool {
+	switch {
+	case c == '#', c == ' ', c == '\t', c == '\r', c == '\n', c == '{', c == '_', c == ':',
+		c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	}
+	return false
+}
+
+// decompressReader is the streaming counterpart of decompressScrape for
+// gzip, zstd and framed snappy. Raw snappy blocks can only be detected in
+// full and are not supported.
+func decompressReader(r io.Reader) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	// A short peek just means the input is short
+	head, _ := br.Peek(len(snappyFramedMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, encodingGzip, fmt.Errorf("decompressing gzip: %w", err)
+		}
+		return zr, encodingGzip, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		d, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, encodingZstd, fmt.Errorf("decompressing zstd: %w", err)
+		}
+		return d, encodingZstd, nil
+	case bytes.HasPrefix(head, snappyFramedMagic), bytes.HasPrefix(head, s2FramedMagic):
+		return s2.NewReader(br), encodingSnappy, nil
+	}
+	return br, "", nil
+}
+
+// summarizeCompressed decompresses a scrape, summarizes it like
+// summarizeChecked and records its compression.
+func summarizeCompressed(ctx context.Context, raw []byte) (ScrapeSummary, error) {
+	data, encoding, err := decompressScrape(raw)
+	if err != nil {
+		return SummarizeScrape(nil), err
+	}
+	s, err := summarizeChecked(ctx, data)
+	if encoding != "" {
+		s.Summary.setCompression(encoding, int64(len(raw)), int64(len(data)))
+	}
+	return s, err
+}
+
+// setCompression records that the scrape was received compressed with the
+// given encoding and size. The ratio is computed from the decompressed size
+// of the whole scrape, which is larger than Bytes if series were selected.
+func (s *MetricsSummary) setCompression(encoding string, compressedBytes, decompressedBytes int64) {
+	s.Encoding = encoding
+	s.CompressedBytes = compressedBytes
+	if compressedBytes > 0 {
+		s.CompressionRatio = float64(decompressedBytes) / float64(compressedBytes)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompressScrape(t *testing.T) {
+	scrape := []byte("# TYPE up gauge\nup{job=\"api\"} 1\nup{job=\"web\"} 1\n")
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write(scrape)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	zst := enc.EncodeAll(scrape, nil)
+	require.NoError(t, enc.Close())
+
+	var framed bytes.Buffer
+	sw := s2.NewWriter(&framed, s2.WriterSnappyCompat())
+	_, err = sw.Write(scrape)
+	require.NoError(t, err)
+	require.NoError(t, sw.Close())
+
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		encoding string
+	}{
+		{"plain", scrape, ""},
+		{"gzip", gz.Bytes(), encodingGzip},
+		{"zstd", zst, encodingZstd},
+		{"framed snappy", framed.Bytes(), encodingSnappy},
+		{"snappy block", s2.EncodeSnappy(nil, scrape), encodingSnappy},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, encoding, err := decompressScrape(tc.data)
+			require.NoError(t, err)
+			require.Equal(t, tc.encoding, encoding)
+			require.Equal(t, scrape, out)
+
+			out, _, err = decompressScrapeLimit(tc.data, int64(len(scrape)))
+			require.NoError(t, err)
+			require.Equal(t, scrape, out)
+			_, _, err = decompressScrapeLimit(tc.data, int64(len(scrape))-1)
+			require.ErrorIs(t, err, errScrapeTooLarge)
+
+			// Raw snappy blocks can't be streamed
+			if tc.name == "snappy block" {
+				return
+			}
+			r, encoding, err := decompressReader(bytes.NewReader(tc.data))
+			require.NoError(t, err)
+			require.Equal(t, tc.encoding, encoding)
+			streamed, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, scrape, streamed)
+		})
+	}
+
+	// Truncated input is reported instead of failing to parse
+	_, encoding, err := decompressScrape(gz.Bytes()[:gz.Len()/2])
+	require.Error(t, err)
+	require.Equal(t, encodingGzip, encoding)
+
+	summary, err := summarizeCompressed(t.Context(), gz.Bytes())
+	require.NoError(t, err)
+	require.Len(t, summary.Metrics, 1)
+	require.Equal(t, int64(len(scrape)), summary.Summary.Bytes)
+	require.Equal(t, encodingGzip, summary.Summary.Encoding)
+	require.Equal(t, int64(gz.Len()), summary.Summary.CompressedBytes)
+	require.InDelta(t, float64(len(scrape))/float64(gz.Len()), summary.Summary.CompressionRatio, 1e-9)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Status values of a MetricDiff.
+const (
+	diffAdded     = "added"
+	diffRemoved   = "removed"
+	diffChanged   = "changed"
+	diffUnchanged = "unchanged"
+)
+
+// ScrapeDiff compares the summaries of two scrapes of the same target, e.g.
+// before and after a change.
+type ScrapeDiff struct {
+	OldBytes  int64 `json:"old_bytes"`
+	NewBytes  int64 `json:"new_bytes"`
+	OldSeries int   `json:"old_series"`
+	NewSeries int   `json:"new_series"`
+
+	Metrics []MetricDiff `json:"metrics"`
+	Types   []CountDiff  `json:"types"`
+	// Labels compares the number of distinct values per label.
+	Labels []CountDiff `json:"labels"`
+}
+
+// MetricDiff compares a metric family between two scrapes.
+type MetricDiff struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Status         string `json:"status"`
+	OldCardinality int    `json:"old_cardinality"`
+	NewCardinality int    `json:"new_cardinality"`
+	OldSize        int64  `json:"old_size_bytes"`
+	NewSize        int64  `json:"new_size_bytes"`
+}
+
+// CountDiff compares a named count between two scrapes.
+type CountDiff struct {
+	Name string `json:"name"`
+	Old  int    `json:"old"`
+	New  int    `json:"new"`
+}
+
+// DiffScrapeSummaries compares two scrape summaries. Metrics are sorted by the
+// absolute change in cardinality (largest first), then by name; types and
+// labels by name.
+func DiffScrapeSummaries(old, cur ScrapeSummary) ScrapeDiff {
+	d := ScrapeDiff{
+		OldBytes: old.Summary.Bytes,
+		NewBytes: cur.Summary.Bytes,
+	}
+
+	byName := make(map[string]*MetricDiff)
+	for _, m := range old.Metrics {
+		d.OldSeries += m.Cardinality
+		byName[m.Name] = &MetricDiff{Name: m.Name, Type: strings.ToLower(m.Type), Status: diffRemoved, OldCardinality: m.Cardinality, OldSize: m.Size}
+	}
+	for _, m := range cur.Metrics {
+		d.NewSeries += m.Cardinality
+		md, ok := byName[m.Name]
+		if !ok {
+			md = &MetricDiff{Name: m.Name, Status: diffAdded}
+			byName[m.Name] = md
+		} else if md.OldCardinality != m.Cardinality || md.OldSize != m.Size || md.Type != strings.ToLower(m.Type) {
+			md.Status = diffChanged
+		} else {
+			md.Status = diffUnchanged
+		}
+		md.Type = strings.ToLower(m.Type)
+		md.NewCardinality = m.Cardinality
+		md.NewSize = m.Size
+	}
+	for _, md := range byName {
+		d.Metrics = append(d.Metrics, *md)
+	}
+	sort.Slice(d.Metrics, func(i, j int) bool {
+		di, dj := abs(d.Metrics[i].NewCardinality-d.Metrics[i].OldCardinality), abs(d.Metrics[j].NewCardinality-d.Metrics[j].OldCardinality)
+		if di == dj {
+			return d.Metrics[i].Name < d.Metrics[j].Name
+		}
+		return di > dj
+	})
+
+	d.Types = diffCounts(old.Summary.TypesCount, cur.Summary.TypesCount)
+	d.Labels = diffCounts(old.Summary.LabelValueCounts, cur.Summary.LabelValueCounts)
+	return d
+}
+
+// diffCounts merges two count maps into a slice sorted by name.
+func diffCounts(old, cur map[string]int) []CountDiff {
+	merged := make(map[string]*CountDiff)
+	for k, v := range old {
+		merged[k] = &CountDiff{Name: k, Old: v}
+	}
+	for k, v := range cur {
+		if _, ok := merged[k]; !ok {
+			merged[k] = &CountDiff{Name: k}
+		}
+		merged[k].New = v
+	}
+	out := make([]CountDiff, 0, len(merged))
+	for _, c := range merged {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// runDiff implements the `diff` command: it summarizes two scrape files ("-"
+// reads stdin) and prints how the second differs from the first.
+func runDiff(args []string, stdin io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	var outputFormat string
+	fs.StringVar(&outputFormat, "output-format", "terminal", "Output format: terminal, json or markdown")
+	fs.StringVar(&outputFormat, "o", "terminal", "Shorthand for --output-format")
+	selectExpr := fs.String("select", "", "PromQL vector selector restricting the compared series")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: scrapecli diff [flags] OLD NEW")
+	}
+	if fs.Arg(0) == "-" && fs.Arg(1) == "-" {
+		return fmt.Errorf("only one of OLD and NEW can be read from stdin (-)")
+	}
+
+	var selector Selector
+	if *selectExpr != "" {
+		var err error
+		if selector, err = ParseSelector(*selectExpr); err != nil {
+			return err
+		}
+	}
+
+	summaries := make([]ScrapeSummary, 2)
+	for i, path := range fs.Args() {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		if data, _, err = decompressScrape(data); err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		if selector != nil {
+			if data, err = SelectScrape(data, selector); err != nil {
+				return fmt.Errorf("selecting series in %s: %w", path, err)
+			}
+		}
+		summaries[i] = SummarizeScrape(data)
+	}
+	d := DiffScrapeSummaries(summaries[0], summaries[1])
+
+	switch strings.ToLower(outputFormat) {
+	case "json":
+		b, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	case "markdown", "md":
+		_, err := io.WriteString(out, FormatScrapeDiffMarkdown(d))
+		return err
+	default:
+		_, err := io.WriteString(out, FormatScrapeDiffTerminal(d))
+		return err
+	}
+}
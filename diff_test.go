@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffScrapeSummaries(t *testing.T) {
+	old := SummarizeScrape([]byte(`# TYPE alpha counter
+alpha{x="1"} 1
+alpha{x="2"} 1
+# TYPE beta gauge
+beta 1
+# TYPE gamma gauge
+gamma 1
+`))
+	cur := SummarizeScrape([]byte(`# TYPE alpha counter
+alpha{x="1"} 1
+alpha{x="2"} 1
+alpha{x="3"} 1
+# TYPE beta gauge
+beta 1
+# TYPE delta histogram
+delta_bucket{le="+Inf"} 1
+delta_sum 1
+delta_count 1
+`))
+
+	d := DiffScrapeSummaries(old, cur)
+	require.Equal(t, old.Summary.Bytes, d.OldBytes)
+	require.Equal(t, cur.Summary.Bytes, d.NewBytes)
+	require.Equal(t, 4, d.OldSeries)
+	require.Equal(t, 5, d.NewSeries)
+
+	size := func(s ScrapeSummary, name string) int64 {
+		for _, m := range s.Metrics {
+			if m.Name == name {
+				return m.Size
+			}
+		}
+		return 0
+	}
+
+	// Sorted by absolute cardinality change, then name
+	require.Equal(t, []MetricDiff{
+		{Name: "alpha", Type: "counter", Status: diffChanged, OldCardinality: 2, NewCardinality: 3, OldSize: size(old, "alpha"), NewSize: size(cur, "alpha")},
+		{Name: "delta", Type: "histogram", Status: diffAdded, NewCardinality: 1, NewSize: size(cur, "delta")},
+		{Name: "gamma", Type: "gauge", Status: diffRemoved, OldCardinality: 1, OldSize: size(old, "gamma")},
+		{Name: "beta", Type: "gauge", Status: diffUnchanged, OldCardinality: 1, NewCardinality: 1, OldSize: size(old, "beta"), NewSize: size(cur, "beta")},
+	}, d.Metrics)
+
+	require.Equal(t, []CountDiff{
+		{Name: "counter", Old: 1, New: 1},
+		{Name: "gauge", Old: 2, New: 1},
+		{Name: "histogram", Old: 0, New: 1},
+	}, d.Types)
+	require.Equal(t, []CountDiff{
+		{Name: "le", Old: 0, New: 1},
+		{Name: "x", Old: 2, New: 3},
+	}, d.Labels)
+}
+
+func TestRunDiffStdinTwice(t *testing.T) {
+	var out bytes.Buffer
+	err := runDiff([]string{"-", "-"}, strings.NewReader("up 1\n"), &out)
+	require.ErrorContains(t, err, "only one of OLD and NEW")
+	require.Empty(t, out.String())
+}
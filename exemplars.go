@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// exemplarLabelLimit is the maximum combined length, in runes, of the label
+// names and values of an exemplar allowed by OpenMetrics.
+const exemplarLabelLimit = 128
+
+// sampleSuffixes are the suffixes sample names may add to their family name.
+var sampleSuffixes = []string{"_bucket", "_total", "_count", "_sum", "_created", "_gcount", "_gsum"}
+
+// ExemplarSummary describes the exemplars attached to the samples of a metric
+// family. Bytes is their overhead in the OpenMetrics text format and OverLimit
+// counts exemplars whose label set exceeds exemplarLabelLimit runes.
+type ExemplarSummary struct {
+	Count     int      `json:"count"`
+	Labels    []string `json:"labels"`
+	Bytes     int64    `json:"bytes"`
+	OverLimit int      `json:"over_limit"`
+}
+
+// exemplarCollector aggregates exemplars per metric family.
+type exemplarCollector struct {
+	byFamily map[string]*ExemplarSummary
+	labels   map[string]map[string]struct{}
+}
+
+func newExemplarCollector() *exemplarCollector {
+	return &exemplarCollector{
+		byFamily: make(map[string]*ExemplarSummary),
+		labels:   make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *exemplarCollector) add(family string, labelNames, labelValues []string, size int) {
+	e, ok := c.byFamily[family]
+	if !ok {
+		e = &ExemplarSummary{}
+		c.byFamily[family] = e
+		c.labels[family] = make(map[string]struct{})
+	}
+	e.Count++
+	e.Bytes += int64(size)
+	length := 0
+	for i, name := range labelNames {
+		c.labels[family][name] = struct{}{}
+		length += runeLen(name) + runeLen(labelValues[i])
+	}
+	if length > exemplarLabelLimit {
+		e.OverLimit++
+	}
+}
+
+func (c *exemplarCollector) result() map[string]*ExemplarSummary {
+	for family, e := range c.byFamily {
+		e.Labels = make([]string, 0, len(c.labels[family]))
+		for l := range c.labels[family] {
+			e.Labels = append(e.Labels, l)
+		}
+		sort.Strings(e.Labels)
+	}
+	return c.byFamily
+}
+
+// textExemplars collects the exemplars of an OpenMetrics text scrape by metric
+// family. The text parser drops exemplars, so they are read from the raw
+// sample lines; their overhead is the bytes from the end of the sample to the
+// end of the line.
+func textExemplars(data []byte, families []*dto.MetricFamily) map[string]*ExemplarSummary {
+	c := newExemplarCollector()
+	if !bytes.Contains(data, []byte("# {")) {
+		return c.result()
+	}
+
+	names := make(map[string]bool, len(families))
+	for _, mf := range families {
+		names[mf.GetName()] = true
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || !strings.Contains(line, "#") {
+			continue
+		}
+		s, err := parseSampleLine(line)
+		if err != nil || !s.HasExemplar {
+			continue
+		}
+		var labelNames, labelValues []string
+		for _, l := range s.ExemplarLabels {
+			labelNames = append(labelNames, l.Name)
+			labelValues = append(labelValues, l.Value)
+		}
+		overhead := len(line) - len(strings.TrimRight(line[:s.ExemplarStart], " \t"))
+		c.add(familyForSample(s.Name, names), labelNames, labelValues, overhead)
+	}
+	return c.result()
+}
+
+// protobufExemplars collects the exemplars of counters and histograms decoded
+// from a protobuf scrape. Their overhead is measured as if the scrape was
+// exposed in the OpenMetrics text format.
+func protobufExemplars(families []*dto.MetricFamily) map[string]*ExemplarSummary {
+	c := newExemplarCollector()
+	for _, mf := range families {
+		for _, m := range mf.Metric {
+			var exemplars []*dto.Exemplar
+			if e := m.GetCounter().GetExemplar(); e != nil {
+				exemplars = append(exemplars, e)
+			}
+			for _, b := range m.GetHistogram().GetBucket() {
+				if e := b.GetExemplar(); e != nil {
+					exemplars = append(exemplars, e)
+				}
+			}
+			exemplars = append(exemplars, m.GetHistogram().GetExemplars()...)
+
+			for _, e := range exemplars {
+				var labelNames, labelValues []string
+				for _, lp := range e.GetLabel() {
+					labelNames = append(labelNames, lp.GetName())
+					labelValues = append(labelValues, lp.GetValue())
+				}
+				c.add(mf.GetName(), labelNames, labelValues, len(exemplarText(e)))
+			}
+		}
+	}
+	return c.result()
+}
+
+// exemplarText returns an exemplar as it follows a sample in the OpenMetrics
+// text format, including the separating blank.
+func exemplarText(e *dto.Exemplar) string {
+	labels := formatSeries("", e.GetLabel())
+	if labels == "" {
+		labels = "{}"
+	}
+	text := " # " + labels + " " + formatValue(e.GetValue())
+	if ts := e.GetTimestamp(); ts != nil {
+		text += fmt.Sprintf(" %.3f", float64(ts.AsTime().UnixMilli())/1000)
+	}
+	return text
+}
+
+// stripExemplars removes OpenMetrics exemplars from the sample lines of data,
+// since the Prometheus text parser rejects them. Other lines are kept as is.
+func stripExemplars(data []byte) []byte {
+	if !bytes.Contains(data, []byte("# {")) {
+		return data
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if s, err := parseSampleLine(line); err == nil && s.HasExemplar {
+			lines[i] = strings.TrimRight(line[:s.ExemplarStart], " \t")
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// familyForSample returns the family a sample belongs to: the sample name
+// itself or, for suffixed samples like `foo_bucket`, the name without the
+// suffix if such a family exists.
+func familyForSample(name string, families map[string]bool) string {
+	if families[name] {
+		return name
+	}
+	for _, suffix := range sampleSuffixes {
+		if base, ok := strings.CutSuffix(name, suffix); ok && families[base] {
+			return base
+		}
+	}
+	return name
+}
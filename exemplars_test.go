@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeScrape_TextExemplars(t *testing.T) {
+	long := strings.Repeat("x", 130)
+	data := []byte(`# TYPE requests counter
+requests_total{code="200"} 3 # {trace_id="abc"} 1 1700000000.000
+requests_total{code="500"} 1
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="1"} 1 # {trace_id="` + long + `"} 0.5
+latency_seconds_bucket{le="+Inf"} 2 # {span_id="s1"} 3
+latency_seconds_sum 3.5
+latency_seconds_count 2
+`)
+	summary := SummarizeScrape(data)
+	byName := make(map[string]MetricSummary)
+	for _, m := range summary.Metrics {
+		byName[m.Name] = m
+	}
+
+	// The OpenMetrics counter keeps its declared name and type
+	require.Len(t, summary.Metrics, 2)
+	require.Equal(t, "COUNTER", byName["requests"].Type)
+	require.Equal(t, "HISTOGRAM", byName["latency_seconds"].Type)
+
+	// The exemplars are ignored when parsing, but inventoried
+	require.Equal(t, 2, byName["requests"].Cardinality)
+	require.Equal(t, &ExemplarSummary{
+		Count:  1,
+		Labels: []string{"trace_id"},
+		Bytes:  int64(len(` # {trace_id="abc"} 1 1700000000.000`)),
+	}, byName["requests"].Exemplars)
+	require.Equal(t, &ExemplarSummary{
+		Count:     2,
+		Labels:    []string{"span_id", "trace_id"},
+		Bytes:     int64(len(` # {trace_id="`+long+`"} 0.5`) + len(` # {span_id="s1"} 3`)),
+		OverLimit: 1,
+	}, byName["latency_seconds"].Exemplars)
+}
+
+func TestSummarizeScrape_ProtobufExemplars(t *testing.T) {
+	name, help, labelName, labelValue := "requests_total", "Requests.", "trace_id", "abc"
+	typ := dto.MetricType_COUNTER
+	value, exemplarValue := 3.0, 1.0
+	mf := &dto.MetricFamily{
+		Name: &name,
+		Help: &help,
+		Type: &typ,
+		Metric: []*dto.Metric{{
+			Counter: &dto.Counter{
+				Value: &value,
+				Exemplar: &dto.Exemplar{
+					Label: []*dto.LabelPair{{Name: &labelName, Value: &labelValue}},
+					Value: &exemplarValue,
+				},
+			},
+		}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeProtoDelim)).Encode(mf))
+
+	summary := SummarizeScrape(buf.Bytes())
+	require.Equal(t, int64(buf.Len()), summary.Summary.Bytes)
+	require.Len(t, summary.Metrics, 1)
+	require.Equal(t, "requests_total", summary.Metrics[0].Name)
+	require.Positive(t, summary.Metrics[0].Size)
+	require.Equal(t, &ExemplarSummary{
+		Count:  1,
+		Labels: []string{"trace_id"},
+		Bytes:  int64(len(` # {trace_id="abc"} 1`)),
+	}, summary.Metrics[0].Exemplars)
+}
+
+func TestStripExemplars(t *testing.T) {
+	require.Equal(t, "a_total 1\n# {x=\"y\"} comment\nb 2",
+		string(stripExemplars([]byte("a_total 1 # {trace_id=\"abc\"} 1\n# {x=\"y\"} comment\nb 2"))))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Exposition formats supported by EncodeFamilies.
+const (
+	exposeFormatProm        = "prom"
+	exposeFormatOpenMetrics = "openmetrics"
+)
+
+// EncodeFamilies writes mfs to w in the given exposition format ("prom" for
+// the Prometheus text format or "openmetrics"). HELP and TYPE metadata are
+// kept. Families, series and the label pairs within a series are sorted in
+// place so that the output is deterministic. In OpenMetrics, counters get the
+// `_total` suffix it requires (see withOpenMetricsNames) and created
+// timestamps are written as `_created` samples.
+func EncodeFamilies(w io.Writer, mfs []*dto.MetricFamily, format string) error {
+	sortFamilies(mfs)
+
+	switch format {
+	case exposeFormatProm:
+		for _, mf := range mfs {
+			if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+				return err
+			}
+		}
+	case exposeFormatOpenMetrics:
+		counters := withOpenMetricsNames(mfs)
+		sortFamilies(counters)
+		for _, mf := range counters {
+			if _, err := expfmt.MetricFamilyToOpenMetrics(w, mf, expfmt.WithCreatedLines()); err != nil {
+				return err
+			}
+		}
+		if _, err := expfmt.FinalizeOpenMetrics(w); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported exposition format %q", format)
+	}
+	return nil
+}
+
+// sortFamilies orders families by name, label pairs by name and series by
+// their label pairs.
+func sortFamilies(mfs []*dto.MetricFamily) {
+	sort.SliceStable(mfs, func(i, j int) bool {
+		return mfs[i].GetName() < mfs[j].GetName()
+	})
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			sort.SliceStable(m.Label, func(i, j int) bool {
+				return m.Label[i].GetName() < m.Label[j].GetName()
+			})
+		}
+		sort.SliceStable(mf.Metric, func(i, j int) bool {
+			return labelPairsKey(mf.Metric[i].Label) < labelPairsKey(mf.Metric[j].Label)
+		})
+	}
+}
+
+// labelPairsKey returns a string identifying a (sorted) set of label pairs.
+// The separator bytes cannot appear in valid UTF-8 label names or values.
+func labelPairsKey(lps []*dto.LabelPair) string {
+	var b strings.Builder
+	for _, lp := range lps {
+		b.WriteString(lp.GetName())
+		b.WriteByte(0xfe)
+		b.WriteString(lp.GetValue())
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
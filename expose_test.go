@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeFamilies(t *testing.T) {
+	input := `# HELP b_total Counts b.
+# TYPE b_total counter
+b_total{z="1",a="2"} 3
+b_total{a="1",z="2"} 4
+# TYPE a gauge
+a 1
+`
+	// Same families with series and labels in a different order
+	shuffled := `# TYPE a gauge
+a 1
+# HELP b_total Counts b.
+# TYPE b_total counter
+b_total{z="2",a="1"} 4
+b_total{a="2",z="1"} 3
+`
+
+	encode := func(in, format string) string {
+		mfs, err := decodeFamilies([]byte(in))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, EncodeFamilies(&buf, mfs, format))
+		return buf.String()
+	}
+
+	expected := `# TYPE a gauge
+a 1
+# HELP b_total Counts b.
+# TYPE b_total counter
+b_total{a="1",z="2"} 4
+b_total{a="2",z="1"} 3
+`
+	require.Equal(t, expected, encode(input, exposeFormatProm))
+	require.Equal(t, expected, encode(shuffled, exposeFormatProm))
+
+	expectedOM := `# TYPE a gauge
+a 1.0
+# HELP b Counts b.
+# TYPE b counter
+b_total{a="1",z="2"} 4.0
+b_total{a="2",z="1"} 3.0
+# EOF
+`
+	require.Equal(t, expectedOM, encode(input, exposeFormatOpenMetrics))
+
+	mfs, err := decodeFamilies([]byte(input))
+	require.NoError(t, err)
+	require.Error(t, EncodeFamilies(&bytes.Buffer{}, mfs, "yaml"))
+}
+
+func TestEncodeFamilies_RoundTrip(t *testing.T) {
+	data, err := os.ReadFile("test-resources/prometheus-scrape.txt")
+	require.NoError(t, err)
+
+	original := SummarizeScrape(data)
+
+	for _, format := range []string{exposeFormatProm, exposeFormatOpenMetrics} {
+		mfs, err := decodeFamilies(data)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, EncodeFamilies(&buf, mfs, format))
+		require.True(t, strings.HasPrefix(buf.String(), "# HELP "), format)
+		require.Empty(t, ValidateScrape(buf.Bytes()), format)
+
+		// Re-encoding keeps every family, its metadata and its series. Counter
+		// families are named without `_total` in OpenMetrics.
+		reencoded := SummarizeScrape(buf.Bytes())
+		require.Equal(t, len(original.Metrics), len(reencoded.Metrics), format)
+		byName := make(map[string]MetricSummary)
+		for _, m := range reencoded.Metrics {
+			if m.Type == "COUNTER" && !strings.HasSuffix(m.Name, "_total") {
+				m.Name += "_total"
+			}
+			byName[m.Name] = m
+		}
+		for _, m := range original.Metrics {
+			r, ok := byName[m.Name]
+			require.True(t, ok, "%s: %s", format, m.Name)
+			require.Equal(t, m.Type, r.Type, format)
+			require.Equal(t, m.Description, r.Description, format)
+			require.Equal(t, m.Cardinality, r.Cardinality, format)
+		}
+		require.Equal(t, original.Summary.LabelValueCounts, reencoded.Summary.LabelValueCounts, format)
+	}
+}
@@ -8,22 +8,47 @@ import (
 	"github.com/fatih/color"
 )
 
+// TerminalOptions controls the layout of the terminal output.
+type TerminalOptions struct {
+	// Width is the terminal width in columns. Long names are truncated, HELP
+	// text is wrapped and long label lists are shortened to "+N more" so that
+	// lines fit. Zero disables all of this (e.g. when writing to a pipe).
+	Width int
+	// ExpandLabels lists the labels whose top values are also shown per
+	// family in the Label Values section; "all" expands every label.
+	ExpandLabels []string
+}
+
 // FormatScrapeSummaryTerminal returns a human-readable, colored terminal
 // representation of a ScrapeSummary. It relies on fatih/color for bold/colored
 // text.
 func FormatScrapeSummaryTerminal(s ScrapeSummary) string {
+	return FormatScrapeSummaryTerminalWithOptions(s, TerminalOptions{})
+}
+
+// FormatScrapeSummaryTerminalWithOptions is like FormatScrapeSummaryTerminal
+// but adapts the layout to the given options. Columns of the Top Metrics and
+// Labels sections are aligned; padding is computed on the uncolored text so
+// alignment holds with and without colors.
+func FormatScrapeSummaryTerminalWithOptions(s ScrapeSummary, opts TerminalOptions) string {
 	var b strings.Builder
 
 	bold := color.New(color.Bold).SprintFunc()
 	cyan := color.New(color.FgHiCyan).SprintFunc()
 	yellow := color.New(color.FgHiYellow).SprintFunc()
 	green := color.New(color.FgHiGreen).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
 	// Use a faint/dim style for missing descriptions to show they are less
 	// prominent.
 	dim := color.New(color.Faint).SprintFunc()
 
 	b.WriteString(bold("## Summary") + "\n\n")
-	b.WriteString(fmt.Sprintf("Size: %s\n\n", cyan(humanReadableBytes(s.Summary.Bytes))))
+	if s.Summary.Encoding != "" {
+		b.WriteString(fmt.Sprintf("Size: %s (%s compressed: %s, ratio %.1f)\n\n", cyan(humanReadableBytes(s.Summary.Bytes)),
+			s.Summary.Encoding, cyan(humanReadableBytes(s.Summary.CompressedBytes)), s.Summary.CompressionRatio))
+	} else {
+		b.WriteString(fmt.Sprintf("Size: %s\n\n", cyan(humanReadableBytes(s.Summary.Bytes))))
+	}
 
 	// Top metrics (previously "Top Cardinalities")
 	if len(s.Summary.TopCardinalities) > 0 {
@@ -33,16 +58,69 @@ func FormatScrapeSummaryTerminal(s ScrapeSummary) string {
 			nameToSize[m.Name] = m.Size
 		}
 
+		// Column widths: names are padded, numbers and sizes right-aligned
+		nameW, cardW, sizeW := 0, 0, 0
+		for _, e := range s.Summary.TopCardinalities {
+			nameW = max(nameW, runeLen(e.Name))
+			cardW = max(cardW, len(fmt.Sprintf("%d", e.Cardinality)))
+			if sz := nameToSize[e.Name]; sz > 0 {
+				sizeW = max(sizeW, len(humanReadableBytes(sz)))
+			}
+		}
+		if opts.Width > 0 {
+			fixed := len("  10. : ") + cardW + len(" series")
+			if sizeW > 0 {
+				fixed += len(", ") + sizeW
+			}
+			nameW = min(nameW, max(opts.Width-fixed, minNameWidth))
+		}
+
 		b.WriteString("Top Metrics:\n")
 		for i, e := range s.Summary.TopCardinalities {
 			valueWord := "series"
+			name := truncateRunes(e.Name, nameW)
+			line := fmt.Sprintf("  %2d. %s:%s %s %s", i+1, yellow(name), strings.Repeat(" ", nameW-runeLen(name)), green(fmt.Sprintf("%*d", cardW, e.Cardinality)), valueWord)
 			// Find size for this metric if available
-			sz := nameToSize[e.Name]
-			if sz > 0 {
+			if sz := nameToSize[e.Name]; sz > 0 {
 				// Only the numbers are green; human-readable size is cyan
-				b.WriteString(fmt.Sprintf("  %2d. %s: %s %s, %s\n", i+1, yellow(e.Name), green(fmt.Sprintf("%d", e.Cardinality)), valueWord, cyan(humanReadableBytes(sz))))
+				line += ", " + cyan(fmt.Sprintf("%*s", sizeW, humanReadableBytes(sz)))
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	// Gzip-compressed transfer size and the families adding the most to it. A
+	// family that is much smaller in the scrape than on its own compresses
+	// well together with the rest. Large scrapes have no marginal sizes and
+	// list the largest families on their own instead.
+	if s.Summary.GzipBytes > 0 {
+		b.WriteString(fmt.Sprintf("Gzip: %s (ratio %.1f)\n", cyan(humanReadableBytes(s.Summary.GzipBytes)), float64(s.Summary.Bytes)/float64(s.Summary.GzipBytes)))
+		marginal := false
+		for _, m := range s.Metrics {
+			marginal = marginal || m.GzipMarginalBytes > 0
+		}
+		gzipSize := func(m MetricSummary) int64 {
+			if marginal {
+				return m.GzipMarginalBytes
+			}
+			return m.GzipBytes
+		}
+		byGzip := make([]MetricSummary, 0, len(s.Metrics))
+		for _, m := range s.Metrics {
+			if gzipSize(m) > 0 {
+				byGzip = append(byGzip, m)
+			}
+		}
+		sort.SliceStable(byGzip, func(i, j int) bool {
+			return gzipSize(byGzip[i]) > gzipSize(byGzip[j])
+		})
+		for i, m := range byGzip[:min(len(byGzip), 10)] {
+			if marginal {
+				b.WriteString(fmt.Sprintf("  %2d. %s: +%s, %s on its own\n", i+1, yellow(m.Name),
+					cyan(humanReadableBytes(m.GzipMarginalBytes)), cyan(humanReadableBytes(m.GzipBytes))))
 			} else {
-				b.WriteString(fmt.Sprintf("  %2d. %s: %s %s\n", i+1, yellow(e.Name), green(fmt.Sprintf("%d", e.Cardinality)), valueWord))
+				b.WriteString(fmt.Sprintf("  %2d. %s: %s on its own\n", i+1, yellow(m.Name), cyan(humanReadableBytes(m.GzipBytes))))
 			}
 		}
 		b.WriteString("\n")
@@ -50,26 +128,8 @@ func FormatScrapeSummaryTerminal(s ScrapeSummary) string {
 
 	// Type counts (all metric types)
 	if len(s.Summary.TypesCount) > 0 {
-		// Convert map to slice for deterministic ordering: sort by count desc then name
-		types := make([]struct {
-			Name  string
-			Count int
-		}, 0, len(s.Summary.TypesCount))
-		for k, v := range s.Summary.TypesCount {
-			types = append(types, struct {
-				Name  string
-				Count int
-			}{Name: k, Count: v})
-		}
-		sort.Slice(types, func(i, j int) bool {
-			if types[i].Count == types[j].Count {
-				return types[i].Name < types[j].Name
-			}
-			return types[i].Count > types[j].Count
-		})
-
 		b.WriteString("Types:\n")
-		for _, t := range types {
+		for _, t := range sortedTypeCounts(s.Summary) {
 			metricWord := "metrics"
 			if t.Count == 1 {
 				metricWord = "metric"
@@ -82,26 +142,26 @@ func FormatScrapeSummaryTerminal(s ScrapeSummary) string {
 
 	// Label counts
 	if len(s.Summary.LabelCounts) > 0 {
-		// Convert map to slice for deterministic ordering: sort by distinct value count (LabelValueCounts) desc then name
-		labels := make([]struct {
-			Name       string
-			Count      int
-			ValueCount int
-		}, 0, len(s.Summary.LabelCounts))
-		for k, v := range s.Summary.LabelCounts {
-			vc := s.Summary.LabelValueCounts[k]
-			labels = append(labels, struct {
-				Name       string
-				Count      int
-				ValueCount int
-			}{Name: k, Count: v, ValueCount: vc})
-		}
-		sort.Slice(labels, func(i, j int) bool {
-			if labels[i].ValueCount == labels[j].ValueCount {
-				return labels[i].Name < labels[j].Name
-			}
-			return labels[i].ValueCount > labels[j].ValueCount
-		})
+		labels := sortedLabelCounts(s.Summary)
+
+		// Column widths: names are padded, counts and sizes right-aligned
+		nameW, valueW, countW, bytesW, lengthW := 0, 0, 0, 0, 0
+		for _, l := range labels {
+			nameW = max(nameW, runeLen(l.Name))
+			valueW = max(valueW, len(fmt.Sprintf("%d", l.ValueCount)))
+			countW = max(countW, len(fmt.Sprintf("%d", l.Count)))
+			if st, ok := s.Summary.LabelLengths[l.Name]; ok {
+				bytesW = max(bytesW, len(humanReadableBytes(st.Bytes)))
+				lengthW = max(lengthW, len(formatLabelLength(st)))
+			}
+		}
+		if opts.Width > 0 {
+			fixed := len("  - : ") + valueW + len(" values, ") + countW + len(" metrics")
+			if bytesW > 0 {
+				fixed += len(", ") + bytesW + len(", ") + lengthW
+			}
+			nameW = min(nameW, max(opts.Width-fixed, minNameWidth))
+		}
 
 		b.WriteString("Labels:\n")
 		for _, l := range labels {
@@ -112,23 +172,169 @@ func FormatScrapeSummaryTerminal(s ScrapeSummary) string {
 				metricWord = "metric"
 			}
 
-			valueWord := "values"
+			valueWord := "values,"
 			if distinctValCount == 1 {
-				valueWord = "value"
+				valueWord = "value, "
 			}
 
-			if l.Name == "<none>" {
+			name := truncateRunes(l.Name, nameW)
+			padding := strings.Repeat(" ", nameW-runeLen(name))
+			if l.Name == noneLabelKey {
 				// Special handling for <none> key which won't have values
 				// Only the number is green
-				b.WriteString(fmt.Sprintf("  - %s: %s %s\n", yellow(l.Name), green(fmt.Sprintf("%d", l.Count)), metricWord))
+				b.WriteString(fmt.Sprintf("  - %s:%s %s %s\n", yellow(name), padding, green(fmt.Sprintf("%*d", valueW, l.Count)), metricWord))
 			} else {
 				// Flip order: values first, then metrics. Only the numbers are green; the words remain uncolored
-				b.WriteString(fmt.Sprintf("  - %s: %s, %s\n", yellow(l.Name), green(fmt.Sprintf("%d", distinctValCount))+" "+valueWord, green(fmt.Sprintf("%d", l.Count))+" "+metricWord))
+				line := fmt.Sprintf("  - %s:%s %s %s %s %s", yellow(name), padding,
+					green(fmt.Sprintf("%*d", valueW, distinctValCount)), valueWord,
+					green(fmt.Sprintf("%*d", countW, l.Count)), metricWord)
+				// Bytes spent on the label and the lengths of its values
+				if st, ok := s.Summary.LabelLengths[l.Name]; ok {
+					line += "," + strings.Repeat(" ", len("metrics")-len(metricWord))
+					line += " " + cyan(fmt.Sprintf("%*s", bytesW, humanReadableBytes(st.Bytes))) + ", " + formatLabelLength(st)
+				}
+				b.WriteString(line + "\n")
+			}
+		}
+		// Series with the longest label values, e.g. URLs or SQL
+		if len(s.Summary.LongestLabelValues) > 0 {
+			b.WriteString("  Longest values:\n")
+			for i, v := range s.Summary.LongestLabelValues {
+				prefix := fmt.Sprintf("    %2d. %s (%d bytes): ", i+1, v.Label, v.Length)
+				series := v.Series
+				if opts.Width > 0 {
+					series = truncateRunes(series, max(opts.Width-runeLen(prefix), minNameWidth))
+				}
+				b.WriteString(fmt.Sprintf("    %2d. %s (%s bytes): %s\n", i+1, yellow(v.Label), green(fmt.Sprintf("%d", v.Length)), dim(series)))
 			}
 		}
 		b.WriteString("\n")
 	}
 
+	// Top values of the labels with the most values; expanded labels are
+	// broken down per family as well
+	if labels := labelValuesRows(s, opts.ExpandLabels); len(labels) > 0 {
+		b.WriteString("Label Values (series per value):\n")
+		for _, l := range labels {
+			b.WriteString(fmt.Sprintf("  - %s: %s\n", yellow(l.Name), formatLabelValuesTerminal(s.Summary.LabelTopValues[l.Name], opts.Width, green)))
+			if !l.Expanded {
+				continue
+			}
+			for _, m := range s.Metrics {
+				if lv, ok := m.LabelTopValues[l.Name]; ok {
+					b.WriteString(fmt.Sprintf("      %s: %s\n", m.Name, formatLabelValuesTerminal(lv, opts.Width, green)))
+				}
+			}
+		}
+		if len(opts.ExpandLabels) == 0 {
+			b.WriteString(dim("  Use --expand-label NAME (or all) to show the values per family.") + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	// Rollup by name prefix, nested for deeper levels
+	if len(s.Rollup) > 0 {
+		b.WriteString("Rollup:\n")
+		writeRollupTerminal(&b, s.Rollup, 1, yellow, green, cyan)
+		b.WriteString("\n")
+	}
+
+	// Value sanity findings, with example series per family and check
+	if len(s.ValueFindings) > 0 {
+		b.WriteString("Value Findings:\n")
+		for _, f := range s.ValueFindings {
+			b.WriteString(fmt.Sprintf("  - %s: %s (%s series)\n", yellow(f.Family), f.Message, green(fmt.Sprintf("%d", f.Count))))
+			for _, e := range f.Examples {
+				b.WriteString("      " + dim(e) + "\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	// Exemplars per family, in the order of the Metrics section
+	var withExemplars []MetricSummary
+	for _, m := range s.Metrics {
+		if m.Exemplars != nil {
+			withExemplars = append(withExemplars, m)
+		}
+	}
+	if len(withExemplars) > 0 {
+		b.WriteString("Exemplars:\n")
+		for _, m := range withExemplars {
+			e := m.Exemplars
+			exemplarWord := "exemplars"
+			if e.Count == 1 {
+				exemplarWord = "exemplar"
+			}
+			line := fmt.Sprintf("  - %s: %s %s, %s", yellow(m.Name), green(fmt.Sprintf("%d", e.Count)), exemplarWord, cyan(humanReadableBytes(e.Bytes)))
+			if len(e.Labels) > 0 {
+				line += ", labels: " + strings.Join(e.Labels, ", ")
+			}
+			if e.OverLimit > 0 {
+				line += fmt.Sprintf(", %s over the %d-rune label limit", red(fmt.Sprintf("%d", e.OverLimit)), exemplarLabelLimit)
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	// Histograms with empty buckets, inconsistent layouts or too many buckets
+	var histograms []HistogramAnalysis
+	for _, h := range s.Histograms {
+		if h.NeedsAttention() {
+			histograms = append(histograms, h)
+		}
+	}
+	if len(histograms) > 0 {
+		b.WriteString("Histograms:\n")
+		for _, h := range histograms {
+			labelSetWord := "label sets"
+			if h.LabelSets == 1 {
+				labelSetWord = "label set"
+			}
+			b.WriteString(fmt.Sprintf("  - %s: %s %s × %s buckets = %s series\n", yellow(h.Family),
+				green(fmt.Sprintf("%d", h.LabelSets)), labelSetWord, green(fmt.Sprintf("%d", h.Buckets)), green(fmt.Sprintf("%d", h.Series))))
+			if h.Layouts > 1 {
+				b.WriteString(fmt.Sprintf("      %s different bucket layouts\n", green(fmt.Sprintf("%d", h.Layouts))))
+			}
+			if len(h.EmptyBuckets) > 0 {
+				// List the first few bounds only, buckets are often exponential
+				var bounds []string
+				for i, ub := range h.EmptyBuckets {
+					if i == maxListedEmptyBuckets {
+						bounds = append(bounds, fmt.Sprintf("+%d more", len(h.EmptyBuckets)-i))
+						break
+					}
+					bounds = append(bounds, formatValue(ub))
+				}
+				b.WriteString(fmt.Sprintf("      empty buckets le=%s: dropping them saves %s series\n", strings.Join(bounds, ", "), green(fmt.Sprintf("%d", h.SavedByDroppingEmpty))))
+			}
+			if h.OverThreshold {
+				b.WriteString(fmt.Sprintf("      too many buckets: reducing to %d saves %s series\n", h.ReduceTo, green(fmt.Sprintf("%d", h.SavedByReducing))))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	// Summaries compared to classic and native histograms
+	if len(s.Summaries) > 0 {
+		b.WriteString("Summaries:\n")
+		var series, histogramSeries, nativeSeries int
+		for _, a := range s.Summaries {
+			series += a.Series
+			histogramSeries += a.HistogramSeries
+			nativeSeries += a.NativeHistogramSeries
+			b.WriteString(fmt.Sprintf("  - %s: %s series today, %s as histogram with %d buckets, %s as native histogram\n", yellow(a.Family),
+				green(fmt.Sprintf("%d", a.Series)), green(fmt.Sprintf("%d", a.HistogramSeries)), a.HistogramBuckets, green(fmt.Sprintf("%d", a.NativeHistogramSeries))))
+			for _, d := range a.Drawbacks {
+				b.WriteString("      " + dim(d) + "\n")
+			}
+		}
+		b.WriteString(fmt.Sprintf("  Total: %s series today, %s as classic histograms, %s as native histograms\n",
+			green(fmt.Sprintf("%d", series)), green(fmt.Sprintf("%d", histogramSeries)), green(fmt.Sprintf("%d", nativeSeries))))
+		b.WriteString(dim("  Summary quantiles can't be aggregated across label sets or instances, histogram buckets can (histogram_quantile).") + "\n\n")
+	}
+
 	// Metrics - render as simple blocks rather than a table
 	b.WriteString(bold("## Metrics") + "\n\n")
 	for _, m := range s.Metrics {
@@ -136,21 +342,30 @@ func FormatScrapeSummaryTerminal(s ScrapeSummary) string {
 		card := green(fmt.Sprintf("%d", m.Cardinality))
 		mType := green(strings.ToLower(m.Type))
 
-		labelsPart := ""
-		if len(m.Labels) > 0 {
-			coloredLabels := make([]string, len(m.Labels))
-			for i, l := range m.Labels {
-				coloredLabels[i] = green(l)
-			}
-			labelsPart = fmt.Sprintf(", labels: %s", strings.Join(coloredLabels, ", "))
-		}
-
 		// pluralize value/values for readability
 		valueWord := "values"
 		if m.Cardinality == 1 {
 			valueWord = "value"
 		}
 
+		labelsPart := ""
+		if len(m.Labels) > 0 {
+			// Show as many labels as fit into the line, then "+N more"
+			shown := len(m.Labels)
+			if opts.Width > 0 {
+				used := runeLen(fmt.Sprintf("%s (type %s, %d %s, labels: )", m.Name, strings.ToLower(m.Type), m.Cardinality, valueWord))
+				shown = fitLabels(m.Labels, opts.Width-used)
+			}
+			coloredLabels := make([]string, 0, shown+1)
+			for _, l := range m.Labels[:shown] {
+				coloredLabels = append(coloredLabels, green(l))
+			}
+			if shown < len(m.Labels) {
+				coloredLabels = append(coloredLabels, fmt.Sprintf("+%d more", len(m.Labels)-shown))
+			}
+			labelsPart = fmt.Sprintf(", labels: %s", strings.Join(coloredLabels, ", "))
+		}
+
 		b.WriteString(fmt.Sprintf("%s (type %s, %s %s%s)\n", name, mType, card, valueWord, labelsPart))
 
 		desc := m.Description
@@ -158,13 +373,124 @@ func FormatScrapeSummaryTerminal(s ScrapeSummary) string {
 			// Use a lightweight/dim color for missing descriptions.
 			b.WriteString(fmt.Sprintf("%s\n\n", dim("<no description>")))
 		} else {
-			b.WriteString(fmt.Sprintf("%s\n\n", dim(desc)))
+			for _, line := range wrapText(desc, opts.Width) {
+				b.WriteString(dim(line) + "\n")
+			}
+			b.WriteString("\n")
 		}
 	}
 
 	return b.String()
 }
 
+// typeCount is a row of the types table.
+type typeCount struct {
+	Name  string
+	Count int
+}
+
+// sortedTypeCounts converts the type counts map to a slice for deterministic
+// ordering: sorted by count desc then name.
+func sortedTypeCounts(s MetricsSummary) []typeCount {
+	types := make([]typeCount, 0, len(s.TypesCount))
+	for k, v := range s.TypesCount {
+		types = append(types, typeCount{Name: k, Count: v})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Count == types[j].Count {
+			return types[i].Name < types[j].Name
+		}
+		return types[i].Count > types[j].Count
+	})
+	return types
+}
+
+// labelCount is a row of the labels table: the number of metrics using the
+// label and the number of distinct values it has across the scrape.
+type labelCount struct {
+	Name       string
+	Count      int
+	ValueCount int
+}
+
+// sortedLabelCounts converts the label count maps to a slice for deterministic
+// ordering: sorted by distinct value count (LabelValueCounts) desc then name.
+func sortedLabelCounts(s MetricsSummary) []labelCount {
+	labels := make([]labelCount, 0, len(s.LabelCounts))
+	for k, v := range s.LabelCounts {
+		labels = append(labels, labelCount{Name: k, Count: v, ValueCount: s.LabelValueCounts[k]})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].ValueCount == labels[j].ValueCount {
+			return labels[i].Name < labels[j].Name
+		}
+		return labels[i].ValueCount > labels[j].ValueCount
+	})
+	return labels
+}
+
+// formatLabelLength describes the value lengths of a label in the Labels
+// section.
+func formatLabelLength(st LabelLengthStats) string {
+	return fmt.Sprintf("length %d–%d (avg %.1f, p99 %d)", st.Min, st.Max, st.Avg, st.P99)
+}
+
+// maxListedLabels is the number of labels listed in the terminal Label Values
+// section unless expanded.
+const maxListedLabels = 10
+
+// maxLabelValueWidth is the number of runes label values are truncated to in
+// the terminal output.
+const maxLabelValueWidth = 40
+
+// labelValuesRow is a label of the terminal Label Values section.
+type labelValuesRow struct {
+	Name     string
+	Expanded bool
+}
+
+// labelValuesRows returns the labels of the Label Values section: those with
+// the most distinct values (at least two) as in the Labels section, plus the
+// expanded ones.
+func labelValuesRows(s ScrapeSummary, expand []string) []labelValuesRow {
+	expanded := make(map[string]bool, len(expand))
+	for _, l := range expand {
+		expanded[l] = true
+	}
+	var rows []labelValuesRow
+	for _, l := range sortedLabelCounts(s.Summary) {
+		if _, ok := s.Summary.LabelTopValues[l.Name]; !ok {
+			continue
+		}
+		isExpanded := expanded[expandAllLabels] || expanded[l.Name]
+		if isExpanded || (l.ValueCount > 1 && len(rows) < maxListedLabels) {
+			rows = append(rows, labelValuesRow{Name: l.Name, Expanded: isExpanded})
+		}
+	}
+	return rows
+}
+
+// formatLabelValuesTerminal lists the top values of a label with the number
+// of series they appear in, followed by the long tail.
+func formatLabelValuesTerminal(lv LabelValues, width int, green func(a ...interface{}) string) string {
+	valueWidth := maxLabelValueWidth
+	if width > 0 {
+		valueWidth = min(valueWidth, max(width/4, minNameWidth))
+	}
+	parts := make([]string, 0, len(lv.Top)+1)
+	for _, c := range lv.Top {
+		parts = append(parts, fmt.Sprintf("%s (%s)", truncateRunes(c.Value, valueWidth), green(fmt.Sprintf("%d", c.Series))))
+	}
+	if lv.OtherValues > 0 {
+		valueWord := "values"
+		if lv.OtherValues == 1 {
+			valueWord = "value"
+		}
+		parts = append(parts, fmt.Sprintf("+%d more %s (%s)", lv.OtherValues, valueWord, green(fmt.Sprintf("%d", lv.OtherSeries))))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // humanReadableBytes formats a byte count into a human-friendly string using
 // binary units (KiB, MiB, ...). For values below 1024 it returns "<n> bytes".
 func humanReadableBytes(b int64) string {
@@ -183,3 +509,223 @@ func humanReadableBytes(b int64) string {
 	unit := units[i-1]
 	return fmt.Sprintf("%.2f %s", val, unit)
 }
+
+// writeRollupTerminal writes one line per rollup group, indenting children
+// below their parent.
+func writeRollupTerminal(b *strings.Builder, groups []RollupGroup, level int, yellow, green, cyan func(a ...interface{}) string) {
+	indent := strings.Repeat("  ", level)
+	for _, g := range groups {
+		familyWord := "families"
+		if g.Families == 1 {
+			familyWord = "family"
+		}
+		b.WriteString(fmt.Sprintf("%s- %s: %s %s, %s series (%.1f%%), %s (%.1f%%)\n", indent, yellow(g.Name),
+			green(fmt.Sprintf("%d", g.Families)), familyWord,
+			green(fmt.Sprintf("%d", g.Series)), 100*g.SeriesShare,
+			cyan(humanReadableBytes(g.Bytes)), 100*g.BytesShare))
+		writeRollupTerminal(b, g.Children, level+1, yellow, green, cyan)
+	}
+}
+
+// FormatScrapeDiffTerminal returns a human-readable, colored terminal
+// representation of a ScrapeDiff. Only metrics, types and labels that changed
+// are listed.
+func FormatScrapeDiffTerminal(d ScrapeDiff) string {
+	var b strings.Builder
+
+	bold := color.New(color.Bold).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	green := color.New(color.FgHiGreen).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+
+	// Growth is shown in red since more series and bytes are what we try to avoid
+	delta := func(old, cur int64, s string) string {
+		switch {
+		case cur > old:
+			return red(s)
+		case cur < old:
+			return green(s)
+		}
+		return s
+	}
+
+	b.WriteString(bold("## Diff") + "\n\n")
+	b.WriteString(fmt.Sprintf("Size: %s → %s (%s)\n", cyan(humanReadableBytes(d.OldBytes)), cyan(humanReadableBytes(d.NewBytes)), delta(d.OldBytes, d.NewBytes, deltaBytes(d.OldBytes, d.NewBytes))))
+	b.WriteString(fmt.Sprintf("Series: %d → %d (%s)\n\n", d.OldSeries, d.NewSeries, delta(int64(d.OldSeries), int64(d.NewSeries), deltaCount(d.OldSeries, d.NewSeries))))
+
+	var changed []MetricDiff
+	for _, m := range d.Metrics {
+		if m.Status != diffUnchanged {
+			changed = append(changed, m)
+		}
+	}
+	if len(changed) > 0 {
+		b.WriteString("Metrics:\n")
+		for _, m := range changed {
+			switch m.Status {
+			case diffAdded:
+				b.WriteString(fmt.Sprintf("  + %s: %d series, %s\n", yellow(m.Name), m.NewCardinality, humanReadableBytes(m.NewSize)))
+			case diffRemoved:
+				b.WriteString(fmt.Sprintf("  - %s: %d series, %s\n", yellow(m.Name), m.OldCardinality, humanReadableBytes(m.OldSize)))
+			default:
+				b.WriteString(fmt.Sprintf("  ~ %s: %d → %d series (%s), %s\n", yellow(m.Name), m.OldCardinality, m.NewCardinality,
+					delta(int64(m.OldCardinality), int64(m.NewCardinality), deltaCount(m.OldCardinality, m.NewCardinality)),
+					delta(m.OldSize, m.NewSize, deltaBytes(m.OldSize, m.NewSize))))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	for _, section := range []struct {
+		title string
+		word  string
+		rows  []CountDiff
+	}{
+		{"Types", "metrics", d.Types},
+		{"Labels", "values", d.Labels},
+	} {
+		var rows []CountDiff
+		for _, c := range section.rows {
+			if c.Old != c.New {
+				rows = append(rows, c)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		b.WriteString(section.title + ":\n")
+		for _, c := range rows {
+			b.WriteString(fmt.Sprintf("  - %s: %d → %d %s (%s)\n", yellow(c.Name), c.Old, c.New, section.word, delta(int64(c.Old), int64(c.New), deltaCount(c.Old, c.New))))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// deltaCount formats the change between two counts with an up or down
+// indicator, e.g. "▲ +5".
+func deltaCount(old, cur int) string {
+	switch {
+	case cur > old:
+		return fmt.Sprintf("▲ +%d", cur-old)
+	case cur < old:
+		return fmt.Sprintf("▼ -%d", old-cur)
+	}
+	return "±0"
+}
+
+// deltaBytes formats the change between two byte sizes with an up or down
+// indicator, e.g. "▲ +1.50 KiB".
+func deltaBytes(old, cur int64) string {
+	switch {
+	case cur > old:
+		return "▲ +" + humanReadableBytes(cur-old)
+	case cur < old:
+		return "▼ -" + humanReadableBytes(old-cur)
+	}
+	return "±0"
+}
+
+// FormatFleetReportTerminal returns a human-readable report of a FleetReport.
+func FormatFleetReportTerminal(r FleetReport) string {
+	var b strings.Builder
+
+	bold := color.New(color.Bold).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	green := color.New(color.FgHiGreen).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+
+	b.WriteString(bold("## Fleet") + "\n\n")
+	b.WriteString(fmt.Sprintf("Targets: %s, Size: %s, Series: %s, Families: %s\n\n", green(fmt.Sprintf("%d", len(r.Targets))),
+		cyan(humanReadableBytes(r.Bytes)), green(fmt.Sprintf("%d", r.Series)), green(fmt.Sprintf("%d", r.Families))))
+
+	b.WriteString("Targets:\n")
+	for _, t := range r.Targets {
+		if t.Error != "" {
+			b.WriteString(fmt.Sprintf("  - %s: %s\n", yellow(t.Name), red("error: "+t.Error)))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  - %s: %s series, %s families, %s\n", yellow(t.Name), green(fmt.Sprintf("%d", t.Series)),
+			green(fmt.Sprintf("%d", t.Families)), cyan(humanReadableBytes(t.Bytes))))
+	}
+	b.WriteString("\n")
+
+	if len(r.TopFamilies) > 0 {
+		b.WriteString("Top Families:\n")
+		for i, f := range r.TopFamilies {
+			targetWord := "targets"
+			if f.Targets == 1 {
+				targetWord = "target"
+			}
+			contributors := make([]string, len(f.TopTargets))
+			for j, c := range f.TopTargets {
+				contributors[j] = fmt.Sprintf("%s %.0f%%", c.Target, c.Share*100)
+			}
+			b.WriteString(fmt.Sprintf("  %2d. %s: %s series in %d %s, %s (%s)\n", i+1, yellow(f.Name), green(fmt.Sprintf("%d", f.Series)),
+				f.Targets, targetWord, cyan(humanReadableBytes(f.Bytes)), strings.Join(contributors, ", ")))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.SharedLabels) > 0 {
+		b.WriteString("Shared Labels:\n")
+		for _, l := range r.SharedLabels {
+			b.WriteString(fmt.Sprintf("  - %s: %s targets, %s metrics\n", yellow(l.Name), green(fmt.Sprintf("%d", l.Targets)), green(fmt.Sprintf("%d", l.Families))))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// FormatTargetSummariesTerminal formats scraped targets grouped by job.
+func FormatTargetSummariesTerminal(results []TargetSummary) string {
+	var b strings.Builder
+
+	bold := color.New(color.Bold).SprintFunc()
+	cyan := color.New(color.FgHiCyan).SprintFunc()
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	green := color.New(color.FgHiGreen).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+
+	b.WriteString(bold("## Targets") + "\n\n")
+	if len(results) == 0 {
+		b.WriteString("No targets found.\n\n")
+		return b.String()
+	}
+
+	// Results are sorted by job, so each job is a contiguous run
+	for i := 0; i < len(results); {
+		job := results[i].Job
+		j := i
+		for j < len(results) && results[j].Job == job {
+			j++
+		}
+		targetWord := "targets"
+		if j-i == 1 {
+			targetWord = "target"
+		}
+		b.WriteString(fmt.Sprintf("Job %s (%d %s):\n", bold(job), j-i, targetWord))
+		for _, r := range results[i:j] {
+			switch {
+			case r.Error != "":
+				b.WriteString(fmt.Sprintf("  - %s (%s): %s\n", yellow(r.Instance), r.URL, red("error: "+r.Error)))
+			case r.Summary == nil:
+				b.WriteString(fmt.Sprintf("  - %s (%s)\n", yellow(r.Instance), r.URL))
+			default:
+				series := 0
+				for _, m := range r.Summary.Metrics {
+					series += m.Cardinality
+				}
+				b.WriteString(fmt.Sprintf("  - %s (%s): %s series, %s families, %s\n", yellow(r.Instance), r.URL,
+					green(fmt.Sprintf("%d", series)), green(fmt.Sprintf("%d", len(r.Summary.Metrics))), cyan(humanReadableBytes(r.Summary.Summary.Bytes))))
+			}
+		}
+		b.WriteString("\n")
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sections of a ScrapeSummary that can be exported as a table.
+const (
+	sectionMetrics = "metrics"
+	sectionLabels  = "labels"
+	sectionTypes   = "types"
+)
+
+// FormatScrapeSummaryCSV writes one section of a ScrapeSummary as a table
+// with a header row. With comma ',' the output is RFC 4180 CSV (quoted fields,
+// CRLF line endings); with '\t' it is TSV with LF line endings.
+//
+// The metrics section has one row per MetricSummary, the labels section one
+// row per label (LabelCounts and LabelValueCounts) and the types section one
+// row per metric type.
+func FormatScrapeSummaryCSV(w io.Writer, s ScrapeSummary, section string, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.UseCRLF = comma == ','
+
+	var rows [][]string
+	switch section {
+	case sectionMetrics, "":
+		rows = append(rows, []string{"name", "type", "cardinality", "size_bytes", "label_count", "labels", "help"})
+		for _, m := range s.Metrics {
+			rows = append(rows, []string{
+				m.Name,
+				strings.ToLower(m.Type),
+				strconv.Itoa(m.Cardinality),
+				strconv.FormatInt(m.Size, 10),
+				strconv.Itoa(len(m.Labels)),
+				strings.Join(m.Labels, ","),
+				m.Description,
+			})
+		}
+	case sectionLabels:
+		rows = append(rows, []string{"label", "metric_count", "distinct_values"})
+		for _, l := range sortedLabelCounts(s.Summary) {
+			rows = append(rows, []string{l.Name, strconv.Itoa(l.Count), strconv.Itoa(l.ValueCount)})
+		}
+	case sectionTypes:
+		rows = append(rows, []string{"type", "metric_count"})
+		for _, t := range sortedTypeCounts(s.Summary) {
+			rows = append(rows, []string{t.Name, strconv.Itoa(t.Count)})
+		}
+	default:
+		return fmt.Errorf("unknown section %q (expected %s, %s or %s)", section, sectionMetrics, sectionLabels, sectionTypes)
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
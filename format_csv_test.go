@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatScrapeSummaryCSV(t *testing.T) {
+	s := ScrapeSummary{
+		Summary: MetricsSummary{
+			TypesCount:       map[string]int{"gauge": 2, "counter": 1},
+			LabelCounts:      map[string]int{"env": 2, "job": 1, noneLabelKey: 1},
+			LabelValueCounts: map[string]int{"env": 3, "job": 1},
+		},
+		Metrics: []MetricSummary{
+			{Name: "metric_a", Type: "GAUGE", Description: `Help with "quotes", commas`, Cardinality: 3, Labels: []string{"env", "job"}, Size: 1024},
+			{Name: "metric_b", Type: "COUNTER", Description: "Multi\nline", Cardinality: 1},
+		},
+	}
+
+	format := func(section string, comma rune) string {
+		var buf bytes.Buffer
+		require.NoError(t, FormatScrapeSummaryCSV(&buf, s, section, comma))
+		return buf.String()
+	}
+
+	require.Equal(t, "name,type,cardinality,size_bytes,label_count,labels,help\r\n"+
+		"metric_a,gauge,3,1024,2,\"env,job\",\"Help with \"\"quotes\"\", commas\"\r\n"+
+		"metric_b,counter,1,0,0,,\"Multi\r\nline\"\r\n", format(sectionMetrics, ','))
+
+	require.Equal(t, "name\ttype\tcardinality\tsize_bytes\tlabel_count\tlabels\thelp\n"+
+		"metric_a\tgauge\t3\t1024\t2\tenv,job\t\"Help with \"\"quotes\"\", commas\"\n"+
+		"metric_b\tcounter\t1\t0\t0\t\t\"Multi\nline\"\n", format(sectionMetrics, '\t'))
+
+	require.Equal(t, "label,metric_count,distinct_values\r\nenv,2,3\r\njob,1,1\r\n<none>,1,0\r\n", format(sectionLabels, ','))
+	require.Equal(t, "type\tmetric_count\ngauge\t2\ncounter\t1\n", format(sectionTypes, '\t'))
+
+	require.Error(t, FormatScrapeSummaryCSV(&bytes.Buffer{}, s, "bogus", ','))
+}
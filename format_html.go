@@ -0,0 +1,49 @@
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed templates/report.html
+var htmlReportTemplate string
+
+var htmlReport = template.Must(template.New("report").Parse(htmlReportTemplate))
+
+// htmlReportData is the data embedded into the HTML report. Additional
+// analysis results are added as fields so the page script can render them.
+type htmlReportData struct {
+	Summary ScrapeSummary `json:"summary"`
+	Tree    *PrefixNode   `json:"tree"`
+}
+
+// FormatScrapeSummaryHTML writes a self-contained, offline HTML report of a
+// ScrapeSummary: headline numbers, a treemap of series and bytes by metric
+// name prefix, bar charts for types and labels and a sortable, searchable
+// family table with a per-family label drill-down. All styles, scripts and
+// data are embedded; the page loads nothing from the network.
+func FormatScrapeSummaryHTML(w io.Writer, s ScrapeSummary, title string) error {
+	series := 0
+	for _, m := range s.Metrics {
+		series += m.Cardinality
+	}
+	return htmlReport.Execute(w, struct {
+		Title    string
+		Size     string
+		Families int
+		Series   int
+		Labels   int
+		Data     htmlReportData
+	}{
+		Title:    title,
+		Size:     humanReadableBytes(s.Summary.Bytes),
+		Families: len(s.Metrics),
+		Series:   series,
+		Labels:   len(s.Summary.LabelValueCounts),
+		Data: htmlReportData{
+			Summary: s,
+			Tree:    buildPrefixTree(s.Metrics),
+		},
+	})
+}
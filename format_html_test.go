@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatScrapeSummaryHTML(t *testing.T) {
+	data, err := os.ReadFile("test-resources/prometheus-scrape.txt")
+	require.NoError(t, err)
+	s := SummarizeScrape(data)
+
+	var buf bytes.Buffer
+	require.NoError(t, FormatScrapeSummaryHTML(&buf, s, "Quarterly <review>"))
+	out := buf.String()
+
+	require.Contains(t, out, "<title>Quarterly &lt;review&gt;</title>")
+
+	// Self-contained: nothing is loaded from the network
+	require.NotRegexp(t, `(src|href)=["']?(https?:)?//`, out)
+	require.NotContains(t, out, "@import")
+
+	// The embedded data round-trips to the summary and the prefix tree
+	m := regexp.MustCompile(`(?s)<script id="report-data" type="application/json">(.*?)</script>`).FindStringSubmatch(out)
+	require.NotNil(t, m, "report data not found")
+	var embedded htmlReportData
+	require.NoError(t, json.Unmarshal([]byte(m[1]), &embedded))
+	require.Equal(t, s.Summary.Bytes, embedded.Summary.Summary.Bytes)
+	require.Equal(t, len(s.Metrics), len(embedded.Summary.Metrics))
+	require.Equal(t, len(s.Metrics), embedded.Tree.Families)
+}
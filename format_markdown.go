@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// markdownCollapseRows is the number of table rows above which a table is
+// wrapped in a collapsible <details> block.
+const markdownCollapseRows = 10
+
+// FormatScrapeSummaryMarkdown returns a GitHub-flavored Markdown report of a
+// ScrapeSummary, suitable for pull request comments. Long tables are wrapped
+// in collapsible <details> blocks.
+func FormatScrapeSummaryMarkdown(s ScrapeSummary) string {
+	var b strings.Builder
+
+	series := 0
+	for _, m := range s.Metrics {
+		series += m.Cardinality
+	}
+
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| Size | Metrics | Series |\n|---:|---:|---:|\n")
+	b.WriteString(fmt.Sprintf("| %s | %d | %d |\n\n", humanReadableBytes(s.Summary.Bytes), len(s.Metrics), series))
+
+	if len(s.Summary.TopCardinalities) > 0 {
+		nameToSize := make(map[string]int64, len(s.Metrics))
+		for _, m := range s.Metrics {
+			nameToSize[m.Name] = m.Size
+		}
+
+		rows := make([][]string, 0, len(s.Summary.TopCardinalities))
+		for i, e := range s.Summary.TopCardinalities {
+			rows = append(rows, []string{fmt.Sprintf("%d", i+1), mdCode(e.Name), fmt.Sprintf("%d", e.Cardinality), humanReadableBytes(nameToSize[e.Name])})
+		}
+		b.WriteString("### Top Metrics\n\n")
+		writeMarkdownTable(&b, "metrics", []string{"#", "Metric", "Series", "Size"}, "---:|---|---:|---:", rows)
+	}
+
+	if len(s.Summary.TypesCount) > 0 {
+		var rows [][]string
+		for _, t := range sortedTypeCounts(s.Summary) {
+			rows = append(rows, []string{t.Name, fmt.Sprintf("%d", t.Count)})
+		}
+		b.WriteString("### Types\n\n")
+		writeMarkdownTable(&b, "types", []string{"Type", "Metrics"}, "---|---:", rows)
+	}
+
+	if len(s.Summary.LabelCounts) > 0 {
+		var rows [][]string
+		for _, l := range sortedLabelCounts(s.Summary) {
+			values := fmt.Sprintf("%d", l.ValueCount)
+			if l.Name == noneLabelKey {
+				values = "–"
+			}
+			rows = append(rows, []string{mdCode(l.Name), values, fmt.Sprintf("%d", l.Count)})
+		}
+		b.WriteString("### Labels\n\n")
+		writeMarkdownTable(&b, "labels", []string{"Label", "Values", "Metrics"}, "---|---:|---:", rows)
+	}
+
+	b.WriteString("## Metrics\n\n")
+	rows := make([][]string, 0, len(s.Metrics))
+	for _, m := range s.Metrics {
+		labels := make([]string, len(m.Labels))
+		for i, l := range m.Labels {
+			labels[i] = mdCode(l)
+		}
+		desc := m.Description
+		if desc == "" {
+			desc = "_no description_"
+		} else {
+			desc = mdEscape(desc)
+		}
+		rows = append(rows, []string{mdCode(m.Name), strings.ToLower(m.Type), fmt.Sprintf("%d", m.Cardinality), humanReadableBytes(m.Size), strings.Join(labels, ", "), desc})
+	}
+	writeMarkdownTable(&b, "metrics", []string{"Metric", "Type", "Series", "Size", "Labels", "Description"}, "---|---|---:|---:|---|---", rows)
+
+	return b.String()
+}
+
+// FormatScrapeDiffMarkdown returns a GitHub-flavored Markdown report of a
+// ScrapeDiff. Deltas are marked with ▲ (growth) and ▼ (reduction); unchanged
+// metrics, types and labels are omitted.
+func FormatScrapeDiffMarkdown(d ScrapeDiff) string {
+	var b strings.Builder
+
+	b.WriteString("## Scrape Diff\n\n")
+	b.WriteString("| | Before | After | Change |\n|---|---:|---:|---:|\n")
+	b.WriteString(fmt.Sprintf("| Size | %s | %s | %s |\n", humanReadableBytes(d.OldBytes), humanReadableBytes(d.NewBytes), deltaBytes(d.OldBytes, d.NewBytes)))
+	b.WriteString(fmt.Sprintf("| Series | %d | %d | %s |\n\n", d.OldSeries, d.NewSeries, deltaCount(d.OldSeries, d.NewSeries)))
+
+	var rows [][]string
+	for _, m := range d.Metrics {
+		if m.Status == diffUnchanged {
+			continue
+		}
+		rows = append(rows, []string{
+			mdCode(m.Name), m.Type, m.Status,
+			fmt.Sprintf("%d", m.OldCardinality), fmt.Sprintf("%d", m.NewCardinality), deltaCount(m.OldCardinality, m.NewCardinality),
+			deltaBytes(m.OldSize, m.NewSize),
+		})
+	}
+	if len(rows) > 0 {
+		b.WriteString("### Metrics\n\n")
+		writeMarkdownTable(&b, "changed metrics", []string{"Metric", "Type", "Status", "Series before", "Series after", "Change", "Size change"}, "---|---|---|---:|---:|---:|---:", rows)
+	}
+
+	for _, section := range []struct {
+		title, noun, column string
+		counts              []CountDiff
+	}{
+		{"Types", "changed types", "Metrics", d.Types},
+		{"Labels", "changed labels", "Values", d.Labels},
+	} {
+		var rows [][]string
+		for _, c := range section.counts {
+			if c.Old != c.New {
+				rows = append(rows, []string{mdCode(c.Name), fmt.Sprintf("%d", c.Old), fmt.Sprintf("%d", c.New), deltaCount(c.Old, c.New)})
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		b.WriteString("### " + section.title + "\n\n")
+		writeMarkdownTable(&b, section.noun, []string{section.title[:len(section.title)-1], section.column + " before", section.column + " after", "Change"}, "---|---:|---:|---:", rows)
+	}
+
+	return b.String()
+}
+
+// writeMarkdownTable writes a table with the given header and alignment row.
+// Tables with more than markdownCollapseRows rows are wrapped in a <details>
+// block whose summary names the number of rows.
+func writeMarkdownTable(b *strings.Builder, noun string, header []string, align string, rows [][]string) {
+	collapse := len(rows) > markdownCollapseRows
+	if collapse {
+		b.WriteString(fmt.Sprintf("<details>\n<summary>%d %s</summary>\n\n", len(rows), noun))
+	}
+	b.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	b.WriteString("|" + align + "|\n")
+	for _, r := range rows {
+		b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+	}
+	b.WriteString("\n")
+	if collapse {
+		b.WriteString("</details>\n\n")
+	}
+}
+
+// mdEscape escapes text for use inside a Markdown table cell.
+func mdEscape(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		"|", `\|`,
+		"<", "&lt;",
+		">", "&gt;",
+		"*", `\*`,
+		"_", `\_`,
+		"`", "\\`",
+		"\n", "<br>",
+	)
+	return r.Replace(s)
+}
+
+// mdCode formats a name as inline code for use inside a Markdown table cell.
+func mdCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
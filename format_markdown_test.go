@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatScrapeSummaryMarkdown(t *testing.T) {
+	s := ScrapeSummary{
+		Summary: MetricsSummary{
+			Bytes: 12345,
+			TopCardinalities: []CardinalityEntry{
+				{Name: "metric_high_card", Cardinality: 100},
+			},
+			TypesCount:       map[string]int{"gauge": 2},
+			LabelCounts:      map[string]int{"env": 1, noneLabelKey: 1},
+			LabelValueCounts: map[string]int{"env": 3},
+		},
+		Metrics: []MetricSummary{
+			{Name: "metric_high_card", Type: "GAUGE", Description: "A | piped <b>description</b>", Cardinality: 100, Labels: []string{"env"}, Size: 10240},
+			{Name: "metric_no_desc", Type: "GAUGE", Cardinality: 1, Size: 20},
+		},
+	}
+
+	expected := "## Summary\n\n" +
+		"| Size | Metrics | Series |\n|---:|---:|---:|\n| 12.06 KiB | 2 | 101 |\n\n" +
+		"### Top Metrics\n\n" +
+		"| # | Metric | Series | Size |\n|---:|---|---:|---:|\n| 1 | `metric_high_card` | 100 | 10.00 KiB |\n\n" +
+		"### Types\n\n" +
+		"| Type | Metrics |\n|---|---:|\n| gauge | 2 |\n\n" +
+		"### Labels\n\n" +
+		"| Label | Values | Metrics |\n|---|---:|---:|\n| `env` | 3 | 1 |\n| `<none>` | – | 1 |\n\n" +
+		"## Metrics\n\n" +
+		"| Metric | Type | Series | Size | Labels | Description |\n|---|---|---:|---:|---|---|\n" +
+		"| `metric_high_card` | gauge | 100 | 10.00 KiB | `env` | A \\| piped &lt;b&gt;description&lt;/b&gt; |\n" +
+		"| `metric_no_desc` | gauge | 1 | 20 bytes |  | _no description_ |\n\n"
+
+	require.Equal(t, expected, FormatScrapeSummaryMarkdown(s))
+
+	// Long lists are collapsible
+	for i := 0; i < markdownCollapseRows; i++ {
+		s.Metrics = append(s.Metrics, MetricSummary{Name: fmt.Sprintf("m%d", i), Type: "GAUGE", Cardinality: 1})
+	}
+	out := FormatScrapeSummaryMarkdown(s)
+	require.Contains(t, out, "## Metrics\n\n<details>\n<summary>12 metrics</summary>\n\n| Metric |")
+	require.True(t, strings.HasSuffix(out, "|\n\n</details>\n\n"))
+}
+
+func TestFormatScrapeDiffMarkdown(t *testing.T) {
+	d := ScrapeDiff{
+		OldBytes: 2048, NewBytes: 1024, OldSeries: 10, NewSeries: 12,
+		Metrics: []MetricDiff{
+			{Name: "grown", Type: "counter", Status: diffChanged, OldCardinality: 5, NewCardinality: 8, OldSize: 100, NewSize: 160},
+			{Name: "same", Type: "gauge", Status: diffUnchanged, OldCardinality: 1, NewCardinality: 1},
+		},
+		Types:  []CountDiff{{Name: "counter", Old: 1, New: 1}},
+		Labels: []CountDiff{{Name: "path", Old: 4, New: 2}},
+	}
+
+	expected := "## Scrape Diff\n\n" +
+		"| | Before | After | Change |\n|---|---:|---:|---:|\n" +
+		"| Size | 2.00 KiB | 1.00 KiB | ▼ -1.00 KiB |\n" +
+		"| Series | 10 | 12 | ▲ +2 |\n\n" +
+		"### Metrics\n\n" +
+		"| Metric | Type | Status | Series before | Series after | Change | Size change |\n|---|---|---|---:|---:|---:|---:|\n" +
+		"| `grown` | counter | changed | 5 | 8 | ▲ +3 | ▲ +60 bytes |\n\n" +
+		"### Labels\n\n" +
+		"| Label | Values before | Values after | Change |\n|---|---:|---:|---:|\n" +
+		"| `path` | 4 | 2 | ▼ -2 |\n\n"
+
+	require.Equal(t, expected, FormatScrapeDiffMarkdown(d))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Values a treemap or folded-stack output can be sized by.
+const (
+	sizeBySeries = "series"
+	sizeByBytes  = "bytes"
+)
+
+// Dimensions of the SVG treemap.
+const (
+	svgWidth       = 1200.0
+	svgHeight      = 800.0
+	svgHeaderSize  = 28.0
+	svgGroupHeader = 14.0
+	svgPadding     = 2.0
+)
+
+// prefixValue returns the value function for a sizeBy setting.
+func prefixValue(sizeBy string) (func(*PrefixNode) float64, error) {
+	switch sizeBy {
+	case sizeBySeries, "":
+		return func(n *PrefixNode) float64 { return float64(n.Series) }, nil
+	case sizeByBytes:
+		return func(n *PrefixNode) float64 { return float64(n.Bytes) }, nil
+	}
+	return nil, fmt.Errorf("unknown size %q (expected %s or %s)", sizeBy, sizeBySeries, sizeByBytes)
+}
+
+// FormatScrapeSummarySVG writes an SVG treemap of the scrape composition.
+// Metric names are split on "_" into nested namespace rectangles (e.g.
+// prometheus → tsdb → compaction) sized by series or bytes. Each rectangle
+// carries a tooltip with the details of its group or MetricSummary.
+func FormatScrapeSummarySVG(w io.Writer, s ScrapeSummary, sizeBy string) error {
+	value, err := prefixValue(sizeBy)
+	if err != nil {
+		return err
+	}
+	if sizeBy == "" {
+		sizeBy = sizeBySeries
+	}
+
+	root := buildPrefixTree(s.Metrics)
+	byName := make(map[string]MetricSummary, len(s.Metrics))
+	for _, m := range s.Metrics {
+		byName[m.Name] = m
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="Verdana, sans-serif" font-size="11">`+"\n",
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(bw, `<text x="4" y="18" font-size="15" font-weight="bold">Scrape composition by %s: %d series, %s, %d families</text>`+"\n",
+		html.EscapeString(sizeBy), root.Series, html.EscapeString(humanReadableBytes(root.Bytes)), root.Families)
+
+	t := svgTreemap{w: bw, value: value, byName: byName}
+	rects := squarify(root.Children, 0, svgHeaderSize, svgWidth, svgHeight-svgHeaderSize, value)
+	for i, r := range rects {
+		t.draw(r, "", (i*47)%360, 0)
+	}
+
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+type svgTreemap struct {
+	w      *bufio.Writer
+	value  func(*PrefixNode) float64
+	byName map[string]MetricSummary
+}
+
+// draw renders a laid out node and, for groups, its children. parent is the
+// prefix of the enclosing group, used to shorten labels.
+func (t svgTreemap) draw(r treemapRect, parent string, hue, depth int) {
+	n := r.Node
+	// Skip prefixes with a single child so that chains like go_gc_duration →
+	// go_gc_duration_seconds don't add empty nesting levels.
+	for !n.Family && len(n.Children) == 1 {
+		n = n.Children[0]
+	}
+
+	label := strings.TrimPrefix(n.Name, parent+"_")
+	if parent == "" {
+		label = n.Name
+	}
+
+	fmt.Fprintf(t.w, `<g><title>%s</title>`, html.EscapeString(t.tooltip(n)))
+	if n.Family {
+		fmt.Fprintf(t.w, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="hsl(%d,65%%,%d%%)" stroke="#ffffff"/>`,
+			r.X, r.Y, r.W, r.H, hue, min(85, 55+depth*6))
+		t.text(r, label, false)
+		fmt.Fprintln(t.w, "</g>")
+		return
+	}
+
+	fmt.Fprintf(t.w, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="hsl(%d,40%%,93%%)" stroke="hsl(%d,40%%,60%%)"/>`,
+		r.X, r.Y, r.W, r.H, hue, hue)
+	t.text(r, label, true)
+	fmt.Fprintln(t.w, "</g>")
+
+	// Only nest if there's room for the header and some content
+	if r.W < 4*svgPadding+10 || r.H < svgGroupHeader+2*svgPadding+10 {
+		return
+	}
+	children := squarify(n.Children, r.X+svgPadding, r.Y+svgGroupHeader, r.W-2*svgPadding, r.H-svgGroupHeader-svgPadding, t.value)
+	for _, c := range children {
+		t.draw(c, n.Name, hue, depth+1)
+	}
+}
+
+// text writes label into the top left corner of r if it fits.
+func (t svgTreemap) text(r treemapRect, label string, bold bool) {
+	const charWidth = 6.5
+	if r.H < 12 || r.W < 3*charWidth {
+		return
+	}
+	maxChars := int((r.W - 4) / charWidth)
+	if runes := []rune(label); len(runes) > maxChars {
+		label = string(runes[:max(0, maxChars-1)]) + "…"
+	}
+	weight := ""
+	if bold {
+		weight = ` font-weight="bold"`
+	}
+	fmt.Fprintf(t.w, `<text x="%.2f" y="%.2f"%s>%s</text>`, r.X+3, r.Y+11, weight, html.EscapeString(label))
+}
+
+// tooltip describes a group or a metric family.
+func (t svgTreemap) tooltip(n *PrefixNode) string {
+	if !n.Family {
+		return fmt.Sprintf("%s_*\n%d families, %d series, %s", n.Name, n.Families, n.Series, humanReadableBytes(n.Bytes))
+	}
+	m := t.byName[n.Name]
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s (%s)\n%d series, %s", m.Name, strings.ToLower(m.Type), m.Cardinality, humanReadableBytes(m.Size)))
+	if len(m.Labels) > 0 {
+		b.WriteString("\nlabels: " + strings.Join(m.Labels, ", "))
+	}
+	if m.Description != "" {
+		b.WriteString("\n" + m.Description)
+	}
+	return b.String()
+}
+
+// FormatFoldedStacks writes the scrape composition in the folded stack format
+// understood by flamegraph tools (e.g. flamegraph.pl, speedscope, inferno):
+// one line per metric family with its name segments as frames, followed by
+// its series or byte count, e.g. `prometheus;tsdb;compactions;total 1`.
+func FormatFoldedStacks(w io.Writer, s ScrapeSummary, sizeBy string) error {
+	if _, err := prefixValue(sizeBy); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, m := range s.Metrics {
+		v := int64(m.Cardinality)
+		if sizeBy == sizeByBytes {
+			v = m.Size
+		}
+		if v == 0 {
+			continue
+		}
+		frames := strings.ReplaceAll(strings.ReplaceAll(m.Name, ";", ":"), "_", ";")
+		fmt.Fprintf(bw, "%s %d\n", frames, v)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var svgTestSummary = ScrapeSummary{
+	Summary: MetricsSummary{Bytes: 300},
+	Metrics: []MetricSummary{
+		{Name: "prometheus_tsdb_compactions_total", Type: "COUNTER", Description: "Total compactions <per> block.", Cardinality: 1, Size: 100},
+		{Name: "prometheus_tsdb_compaction_duration_seconds", Type: "HISTOGRAM", Cardinality: 14, Labels: []string{"le"}, Size: 150},
+		{Name: "up", Type: "GAUGE", Cardinality: 1, Size: 50},
+	},
+}
+
+func TestFormatScrapeSummarySVG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, FormatScrapeSummarySVG(&buf, svgTestSummary, sizeByBytes))
+	out := buf.String()
+
+	// Well-formed XML
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	require.True(t, strings.HasPrefix(out, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	require.Contains(t, out, "Scrape composition by bytes: 16 series, 300 bytes, 3 families")
+	// Single-child prefixes collapse into one group; family tooltips show MetricSummary details
+	require.Contains(t, out, "<title>prometheus_tsdb_*\n2 families, 15 series, 250 bytes</title>")
+	require.Contains(t, out, "<title>prometheus_tsdb_compactions_total (counter)\n1 series, 100 bytes\nTotal compactions &lt;per&gt; block.</title>")
+	require.Contains(t, out, "<title>prometheus_tsdb_compaction_duration_seconds (histogram)\n14 series, 150 bytes\nlabels: le</title>")
+
+	require.Error(t, FormatScrapeSummarySVG(&buf, svgTestSummary, "area"))
+}
+
+func TestFormatFoldedStacks(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, FormatFoldedStacks(&buf, svgTestSummary, sizeBySeries))
+	require.Equal(t, "prometheus;tsdb;compactions;total 1\nprometheus;tsdb;compaction;duration;seconds 14\nup 1\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, FormatFoldedStacks(&buf, svgTestSummary, sizeByBytes))
+	require.Equal(t, "prometheus;tsdb;compactions;total 100\nprometheus;tsdb;compaction;duration;seconds 150\nup 50\n", buf.String())
+}
@@ -34,6 +34,12 @@ func TestFormatScrapeSummaryTerminal(t *testing.T) {
 			{Name: "metric_low_card", Type: "COUNTER", Description: "A low cardinality metric", Cardinality: 2, Labels: []string{"env", "job"}, Size: 1024},
 			{Name: "metric_no_desc", Type: "GAUGE", Description: "", Cardinality: 1},
 		},
+		Rollup: []RollupGroup{
+			{Name: "metric", Families: 3, Series: 103, Bytes: 11264, SeriesShare: 1, BytesShare: 1, Children: []RollupGroup{
+				{Name: "metric_high", Families: 1, Series: 100, Bytes: 10240, SeriesShare: 0.9709, BytesShare: 0.9091},
+				{Name: "metric_low", Families: 1, Series: 2, Bytes: 1024, SeriesShare: 0.0194, BytesShare: 0.0909},
+			}},
+		},
 	}
 
 	out := FormatScrapeSummaryTerminal(s)
@@ -44,7 +50,7 @@ Size: 12.06 KiB
 
 Top Metrics:
    1. metric_high_card: 100 series, 10.00 KiB
-   2. metric_low_card: 2 series, 1.00 KiB
+   2. metric_low_card:    2 series,  1.00 KiB
 
 Types:
   - gauge: 2 metrics
@@ -52,7 +58,12 @@ Types:
 
 Labels:
   - env: 3 values, 2 metrics
-  - job: 1 value, 1 metric
+  - job: 1 value,  1 metric
+
+Rollup:
+  - metric: 3 families, 103 series (100.0%), 11.00 KiB (100.0%)
+    - metric_high: 1 family, 100 series (97.1%), 10.00 KiB (90.9%)
+    - metric_low: 1 family, 2 series (1.9%), 1.00 KiB (9.1%)
 
 ## Metrics
 
@@ -69,3 +80,52 @@ metric_no_desc (type gauge, 1 value)
 
 	require.Equal(t, expected, out, "formatted output should match exactly")
 }
+
+func TestFormatScrapeSummaryTerminalWithOptions(t *testing.T) {
+	color.NoColor = true
+
+	s := ScrapeSummary{
+		Summary: MetricsSummary{
+			Bytes: 2048,
+			TopCardinalities: []CardinalityEntry{
+				{Name: "a_very_long_metric_name_that_does_not_fit", Cardinality: 1000},
+				{Name: "short", Cardinality: 5},
+			},
+			LabelCounts:      map[string]int{"a_very_long_label_name": 1, noneLabelKey: 1},
+			LabelValueCounts: map[string]int{"a_very_long_label_name": 12},
+		},
+		Metrics: []MetricSummary{
+			{Name: "short", Type: "GAUGE", Description: "This help text is long enough that it has to be wrapped onto several lines.", Cardinality: 5,
+				Labels: []string{"alpha", "bravo", "charlie", "delta", "echo"}, Size: 100},
+		},
+	}
+
+	out := FormatScrapeSummaryTerminalWithOptions(s, TerminalOptions{Width: 60})
+
+	expected := `## Summary
+
+Size: 2.00 KiB
+
+Top Metrics:
+   1. a_very_long_metric_name_that_…: 1000 series
+   2. short:                             5 series, 100 bytes
+
+Labels:
+  - a_very_long_label_name: 12 values, 1 metric
+  - <none>:                  1 metric
+
+## Metrics
+
+short (type gauge, 5 values, labels: alpha, bravo, +3 more)
+This help text is long enough that it has to be wrapped onto
+several lines.
+
+`
+	require.Equal(t, expected, out)
+
+	// Without a width nothing is truncated or wrapped
+	out = FormatScrapeSummaryTerminalWithOptions(s, TerminalOptions{})
+	require.Contains(t, out, "   1. a_very_long_metric_name_that_does_not_fit: 1000 series\n")
+	require.Contains(t, out, "labels: alpha, bravo, charlie, delta, echo)\n")
+	require.Contains(t, out, "\nThis help text is long enough that it has to be wrapped onto several lines.\n")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// maxGzipMarginalWork limits the bytes compressed to compute the marginal
+// sizes of families: every family compresses the whole scrape once more.
+const maxGzipMarginalWork = 64 << 20
+
+// familyGzipSize is the gzip-compressed size of a family's lines on their own
+// and its marginal contribution to the compressed size of the whole scrape.
+type familyGzipSize struct {
+	Alone    int64
+	Marginal int64
+}
+
+// gzipSizes returns the gzip-compressed size of a text scrape and the
+// compressed sizes per metric family. Lines are attributed to families by
+// their HELP/TYPE metric name or sample name; other lines (e.g. comments)
+// belong to no family. The marginal size of a family is the difference between
+// the compressed scrape and the compressed scrape without the family's lines.
+// It is left at zero if computing it for every family would compress more
+// than maxGzipMarginalWork bytes. No more families are compressed once ctx is
+// done.
+func gzipSizes(ctx context.Context, data []byte, mfs []*dto.MetricFamily) (int64, map[string]familyGzipSize, error) {
+	names := make(map[string]bool, len(mfs))
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+
+	// owners[i] is the family of line i, or "" if it belongs to none
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	owners := make([]string, len(lines))
+	byFamily := make(map[string][][]byte)
+	for i, line := range lines {
+		owner := lineFamily(string(line), names)
+		if owner != "" && names[owner] {
+			owners[i] = owner
+			byFamily[owner] = append(byFamily[owner], line)
+		}
+	}
+
+	total := gzipLen(lines)
+	marginal := int64(len(byFamily))*int64(len(data)) <= maxGzipMarginalWork
+	sizes := make(map[string]familyGzipSize, len(byFamily))
+	var mu sync.Mutex
+	families := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			without := make([][]byte, 0, len(lines))
+			for family := range families {
+				size := familyGzipSize{Alone: gzipLen(byFamily[family])}
+				if marginal {
+					without = without[:0]
+					for i, line := range lines {
+						if owners[i] != family {
+							without = append(without, line)
+						}
+					}
+					size.Marginal = total - gzipLen(without)
+				}
+				mu.Lock()
+				sizes[family] = size
+				mu.Unlock()
+			}
+		}()
+	}
+dispatch:
+	for family := range byFamily {
+		select {
+		case families <- family:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(families)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	return total, sizes, nil
+}
+
+// lineFamily returns the metric family a line of a text scrape belongs to.
+func lineFamily(line string, names map[string]bool) string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return ""
+	}
+	if strings.HasPrefix(trimmed, "#") {
+		fields := strings.Fields(trimmed)
+		if len(fields) < 3 || fields[0] != "#" || (fields[1] != "HELP" && fields[1] != "TYPE" && fields[1] != "UNIT") {
+			return ""
+		}
+		name, _ := metadataName(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(trimmed[1:]), fields[1])))
+		return name
+	}
+	s, err := parseSampleLine(strings.TrimRight(line, "\n"))
+	if err != nil {
+		return ""
+	}
+	return familyForSample(s.Name, names)
+}
+
+// gzipPool reuses gzip writers, which allocate large buffers.
+var gzipPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// gzipLen returns the size of the concatenated chunks compressed with gzip at
+// the default level.
+func gzipLen(chunks [][]byte) int64 {
+	var c countingWriter
+	w := gzipPool.Get().(*gzip.Writer)
+	defer gzipPool.Put(w)
+	w.Reset(&c)
+	for _, chunk := range chunks {
+		_, _ = w.Write(chunk)
+	}
+	_ = w.Close()
+	return c.n
+}
+
+// countingWriter discards everything written to it but counts the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipSizes(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# HELP repetitive Repeated labels.\n# TYPE repetitive gauge\n")
+	for i := 0; i < 50; i++ {
+		b.WriteString(`repetitive{job="api",instance="localhost:9090"} 1` + "\n")
+	}
+	b.WriteString("# TYPE random histogram\n")
+	for i := 0; i < 50; i++ {
+		b.WriteString("random_bucket{id=\"" + strings.Repeat(string(rune('a'+i%26)), 1+i%7) + string(rune('A'+i%26)) + "\",le=\"+Inf\"} " + strings.Repeat("7", i%9+1) + "\n")
+	}
+	b.WriteString("random_sum 1\nrandom_count 1\n# a plain comment\n")
+	data := []byte(b.String())
+
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+	total, sizes, err := gzipSizes(t.Context(), data, mfs)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, int64(buf.Len()), total)
+
+	require.Len(t, sizes, 2)
+	for _, family := range []string{"repetitive", "random"} {
+		require.Positive(t, sizes[family].Alone, family)
+		require.Positive(t, sizes[family].Marginal, family)
+		require.Less(t, sizes[family].Marginal, total, family)
+	}
+	// Repeated label sets compress far better than varying ones
+	require.Less(t, sizes["repetitive"].Marginal, sizes["random"].Marginal)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, _, err = gzipSizes(ctx, data, mfs)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGzipSizesLargeScrape(t *testing.T) {
+	var b strings.Builder
+	for f := 0; b.Len() < 1<<20; f++ {
+		fmt.Fprintf(&b, "# TYPE family_%d gauge\n", f)
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(&b, "family_%d{id=\"%d\"} %d\n", f, i, f*i)
+		}
+	}
+	data := []byte(b.String())
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+	require.Greater(t, int64(len(mfs))*int64(len(data)), int64(maxGzipMarginalWork))
+
+	// Only the sizes on their own are computed
+	total, sizes, err := gzipSizes(t.Context(), data, mfs)
+	require.NoError(t, err)
+	require.Positive(t, total)
+	require.Len(t, sizes, len(mfs))
+	for family, size := range sizes {
+		require.Positive(t, size.Alone, family)
+		require.Zero(t, size.Marginal, family)
+	}
+}
+
+func TestLineFamily(t *testing.T) {
+	names := map[string]bool{"http_requests": true, "latency_seconds": true}
+	require.Equal(t, "http_requests", lineFamily("# TYPE http_requests counter\n", names))
+	require.Equal(t, "http_requests", lineFamily(`http_requests_total{code="200"} 1`, names))
+	require.Equal(t, "latency_seconds", lineFamily(`latency_seconds_bucket{le="1"} 1`+"\n", names))
+	require.Equal(t, "", lineFamily("# just a comment\n", names))
+	require.Equal(t, "", lineFamily("\n", names))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Defaults for the histogram bucket analysis.
+const (
+	defaultBucketThreshold = 20
+	defaultReduceBuckets   = 10
+)
+
+// maxListedEmptyBuckets is the number of empty bucket bounds listed per
+// histogram in the terminal output.
+const maxListedEmptyBuckets = 5
+
+// HistogramOptions configures AnalyzeHistograms.
+type HistogramOptions struct {
+	// Threshold flags histograms with more buckets (including +Inf).
+	Threshold int
+	// ReduceTo is the bucket count (including +Inf) savings are computed for.
+	ReduceTo int
+}
+
+// HistogramAnalysis describes the bucket layout of a histogram family and the
+// bucket series that could be saved by changing it.
+type HistogramAnalysis struct {
+	Family    string `json:"family"`
+	LabelSets int    `json:"label_sets"`
+	// Buckets is the number of buckets (including +Inf) of the most common
+	// layout, Bounds its finite upper bounds.
+	Buckets int       `json:"buckets"`
+	Bounds  []float64 `json:"bounds"`
+	// Series is the number of bucket series across all label sets.
+	Series int `json:"series"`
+	// Layouts is the number of distinct bucket layouts across label sets;
+	// anything but 1 means the layout is inconsistent.
+	Layouts int `json:"layouts"`
+	// EmptyBuckets are the upper bounds of buckets that never received an
+	// observation in any label set, i.e. whose count equals the previous one.
+	EmptyBuckets  []float64 `json:"empty_buckets,omitempty"`
+	OverThreshold bool      `json:"over_threshold"`
+	// SavedByDroppingEmpty and SavedByReducing are the bucket series saved by
+	// dropping the empty buckets or by reducing every label set to ReduceTo
+	// buckets.
+	SavedByDroppingEmpty int `json:"saved_by_dropping_empty"`
+	SavedByReducing      int `json:"saved_by_reducing"`
+	ReduceTo             int `json:"reduce_to"`
+}
+
+// AnalyzeHistograms reports the bucket layout of every histogram family and
+// how many series dropping empty buckets or reducing the number of buckets to
+// opts.ReduceTo would save. Results are sorted by series (descending), then
+// family.
+func AnalyzeHistograms(mfs []*dto.MetricFamily, opts HistogramOptions) []HistogramAnalysis {
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultBucketThreshold
+	}
+	if opts.ReduceTo <= 0 {
+		opts.ReduceTo = defaultReduceBuckets
+	}
+
+	var analyses []HistogramAnalysis
+	for _, mf := range mfs {
+		if mf.GetType() != dto.MetricType_HISTOGRAM {
+			continue
+		}
+		a := HistogramAnalysis{Family: mf.GetName(), ReduceTo: opts.ReduceTo}
+
+		layouts := make(map[string]int)
+		layoutBounds := make(map[string][]float64)
+		// Per finite upper bound: label sets having it and those where it's empty
+		present := make(map[float64]int)
+		empty := make(map[float64]int)
+		for _, m := range mf.Metric {
+			buckets := sortedBuckets(m.GetHistogram())
+			if len(buckets) == 0 {
+				continue
+			}
+			a.LabelSets++
+			a.Series += len(buckets)
+			a.SavedByReducing += max(len(buckets)-opts.ReduceTo, 0)
+			a.OverThreshold = a.OverThreshold || len(buckets) > opts.Threshold
+
+			var bounds []float64
+			var key strings.Builder
+			prev := 0.0
+			for _, b := range buckets {
+				ub := b.GetUpperBound()
+				key.WriteString(formatValue(ub) + ",")
+				if math.IsInf(ub, 1) {
+					continue
+				}
+				bounds = append(bounds, ub)
+				present[ub]++
+				if bucketCount(b) == prev {
+					empty[ub]++
+				}
+				prev = bucketCount(b)
+			}
+			layouts[key.String()]++
+			layoutBounds[key.String()] = bounds
+		}
+		if a.LabelSets == 0 {
+			continue
+		}
+
+		// The most common layout, ties broken by key for determinism
+		a.Layouts = len(layouts)
+		var common string
+		for k, n := range layouts {
+			if common == "" || n > layouts[common] || (n == layouts[common] && k < common) {
+				common = k
+			}
+		}
+		a.Bounds = layoutBounds[common]
+		a.Buckets = strings.Count(common, ",")
+
+		for ub, n := range present {
+			if empty[ub] == n {
+				a.EmptyBuckets = append(a.EmptyBuckets, ub)
+				a.SavedByDroppingEmpty += n
+			}
+		}
+		sort.Float64s(a.EmptyBuckets)
+
+		analyses = append(analyses, a)
+	}
+	sort.Slice(analyses, func(i, j int) bool {
+		if analyses[i].Series == analyses[j].Series {
+			return analyses[i].Family < analyses[j].Family
+		}
+		return analyses[i].Series > analyses[j].Series
+	})
+	return analyses
+}
+
+// NeedsAttention reports whether the histogram has empty buckets, an
+// inconsistent layout or more buckets than the threshold.
+func (a HistogramAnalysis) NeedsAttention() bool {
+	return len(a.EmptyBuckets) > 0 || a.Layouts > 1 || a.OverThreshold
+}
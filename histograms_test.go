@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeHistograms(t *testing.T) {
+	data := []byte(`# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/a",le="0.1"} 0
+latency_seconds_bucket{path="/a",le="1"} 3
+latency_seconds_bucket{path="/a",le="10"} 3
+latency_seconds_bucket{path="/a",le="+Inf"} 4
+latency_seconds_sum{path="/a"} 20
+latency_seconds_count{path="/a"} 4
+latency_seconds_bucket{path="/b",le="0.1"} 0
+latency_seconds_bucket{path="/b",le="1"} 0
+latency_seconds_bucket{path="/b",le="10"} 2
+latency_seconds_bucket{path="/b",le="+Inf"} 2
+latency_seconds_sum{path="/b"} 5
+latency_seconds_count{path="/b"} 2
+latency_seconds_bucket{path="/c",le="0.5"} 1
+latency_seconds_bucket{path="/c",le="+Inf"} 1
+latency_seconds_sum{path="/c"} 0.2
+latency_seconds_count{path="/c"} 1
+# TYPE size_bytes histogram
+size_bytes_bucket{le="1"} 1
+size_bytes_bucket{le="+Inf"} 2
+size_bytes_sum 3
+size_bytes_count 2
+# TYPE up gauge
+up 1
+`)
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+
+	analyses := AnalyzeHistograms(mfs, HistogramOptions{Threshold: 3, ReduceTo: 2})
+	require.Equal(t, []HistogramAnalysis{
+		{
+			Family:    "latency_seconds",
+			LabelSets: 3,
+			Buckets:   4,
+			Bounds:    []float64{0.1, 1, 10},
+			Series:    10,
+			Layouts:   2,
+			// le="1" received observations for /a, le="10" for /b
+			EmptyBuckets:         []float64{0.1},
+			OverThreshold:        true,
+			SavedByDroppingEmpty: 2,
+			SavedByReducing:      4,
+			ReduceTo:             2,
+		},
+		{
+			Family:    "size_bytes",
+			LabelSets: 1,
+			Buckets:   2,
+			Bounds:    []float64{1},
+			Series:    2,
+			Layouts:   1,
+			ReduceTo:  2,
+		},
+	}, analyses)
+	require.True(t, analyses[0].NeedsAttention())
+	require.False(t, analyses[1].NeedsAttention())
+
+	// Defaults apply to unset options
+	analyses = AnalyzeHistograms(mfs, HistogramOptions{})
+	require.False(t, analyses[0].OverThreshold)
+	require.Equal(t, defaultReduceBuckets, analyses[0].ReduceTo)
+	require.Zero(t, analyses[0].SavedByReducing)
+}
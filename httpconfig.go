@@ -0,0 +1,396 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// PromHTTPConfig is the `http_config` part of a scrape config: how targets are
+// authenticated and reached.
+type PromHTTPConfig struct {
+	BasicAuth       *PromBasicAuth     `yaml:"basic_auth"`
+	Authorization   *PromAuthorization `yaml:"authorization"`
+	BearerToken     string             `yaml:"bearer_token"`
+	BearerTokenFile string             `yaml:"bearer_token_file"`
+
+	TLSConfig PromTLSConfig `yaml:"tls_config"`
+
+	ProxyURL             string              `yaml:"proxy_url"`
+	NoProxy              string              `yaml:"no_proxy"`
+	ProxyFromEnvironment bool                `yaml:"proxy_from_environment"`
+	ProxyConnectHeader   map[string][]string `yaml:"proxy_connect_header"`
+
+	FollowRedirects *bool                 `yaml:"follow_redirects"`
+	HTTPHeaders     map[string]PromHeader `yaml:"http_headers"`
+}
+
+// PromBasicAuth configures HTTP basic authentication.
+type PromBasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// PromAuthorization configures the Authorization header, e.g. a bearer token.
+type PromAuthorization struct {
+	Type            string `yaml:"type"`
+	Credentials     string `yaml:"credentials"`
+	CredentialsFile string `yaml:"credentials_file"`
+}
+
+// PromTLSConfig configures TLS connections to targets. Certificates and keys
+// are given inline (PEM) or as files.
+type PromTLSConfig struct {
+	CA                 string `yaml:"ca"`
+	CAFile             string `yaml:"ca_file"`
+	Cert               string `yaml:"cert"`
+	CertFile           string `yaml:"cert_file"`
+	Key                string `yaml:"key"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	MinVersion         string `yaml:"min_version"`
+}
+
+// PromHeader holds the values of a custom request header. Secrets are values
+// that shouldn't be shown; files hold one value each.
+type PromHeader struct {
+	Values  []string `yaml:"values"`
+	Secrets []string `yaml:"secrets"`
+	Files   []string `yaml:"files"`
+}
+
+// tlsVersions maps the min_version names of Prometheus to TLS versions.
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// validate reports settings that contradict each other, like Prometheus does
+// when loading its configuration.
+func (c *PromHTTPConfig) validate() error {
+	auths := 0
+	if c.BasicAuth != nil {
+		auths++
+		if c.BasicAuth.Password != "" && c.BasicAuth.PasswordFile != "" {
+			return errors.New("at most one of basic_auth password and password_file must be configured")
+		}
+	}
+	if c.Authorization != nil {
+		auths++
+		if c.Authorization.Credentials != "" && c.Authorization.CredentialsFile != "" {
+			return errors.New("at most one of authorization credentials and credentials_file must be configured")
+		}
+		if strings.EqualFold(c.Authorization.Type, "basic") {
+			return errors.New(`authorization type cannot be set to "basic", use basic_auth instead`)
+		}
+	}
+	if c.BearerToken != "" || c.BearerTokenFile != "" {
+		auths++
+		if c.BearerToken != "" && c.BearerTokenFile != "" {
+			return errors.New("at most one of bearer_token and bearer_token_file must be configured")
+		}
+	}
+	if auths > 1 {
+		return errors.New("at most one of basic_auth, authorization and bearer_token must be configured")
+	}
+
+	t := c.TLSConfig
+	if t.CA != "" && t.CAFile != "" {
+		return errors.New("at most one of tls_config ca and ca_file must be configured")
+	}
+	if t.Cert != "" && t.CertFile != "" {
+		return errors.New("at most one of tls_config cert and cert_file must be configured")
+	}
+	if t.Key != "" && t.KeyFile != "" {
+		return errors.New("at most one of tls_config key and key_file must be configured")
+	}
+	if (t.Cert != "" || t.CertFile != "") != (t.Key != "" || t.KeyFile != "") {
+		return errors.New("tls_config needs both a client certificate and key")
+	}
+	if _, ok := tlsVersions[t.MinVersion]; t.MinVersion != "" && !ok {
+		return fmt.Errorf("unknown tls_config min_version %q", t.MinVersion)
+	}
+
+	if c.ProxyURL != "" {
+		if c.ProxyFromEnvironment {
+			return errors.New("at most one of proxy_url and proxy_from_environment must be configured")
+		}
+		if _, err := url.Parse(c.ProxyURL); err != nil {
+			return fmt.Errorf("invalid proxy_url: %w", err)
+		}
+	} else if c.NoProxy != "" {
+		return errors.New("no_proxy requires proxy_url")
+	}
+	return nil
+}
+
+// resolvePaths makes the file paths of the config absolute with resolve.
+func (c *PromHTTPConfig) resolvePaths(resolve func(string) string) {
+	if c.BasicAuth != nil {
+		c.BasicAuth.PasswordFile = resolve(c.BasicAuth.PasswordFile)
+	}
+	if c.Authorization != nil {
+		c.Authorization.CredentialsFile = resolve(c.Authorization.CredentialsFile)
+	}
+	c.BearerTokenFile = resolve(c.BearerTokenFile)
+	c.TLSConfig.CAFile = resolve(c.TLSConfig.CAFile)
+	c.TLSConfig.CertFile = resolve(c.TLSConfig.CertFile)
+	c.TLSConfig.KeyFile = resolve(c.TLSConfig.KeyFile)
+	for name, h := range c.HTTPHeaders {
+		for i, f := range h.Files {
+			h.Files[i] = resolve(f)
+		}
+		c.HTTPHeaders[name] = h
+	}
+}
+
+// newScrapeClient returns the HTTP client for the targets of an HTTP config.
+// As in Prometheus, no proxy is used unless configured.
+func newScrapeClient(c *PromHTTPConfig) (*http.Client, error) {
+	tlsConfig, err := c.TLSConfig.build()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = nil
+	switch {
+	case c.ProxyURL != "":
+		proxy, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		noProxy := c.NoProxy
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if matchesNoProxy(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+			return proxy, nil
+		}
+	case c.ProxyFromEnvironment:
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+	if len(c.ProxyConnectHeader) > 0 {
+		transport.ProxyConnectHeader = http.Header{}
+		for name, values := range c.ProxyConnectHeader {
+			for _, v := range values {
+				transport.ProxyConnectHeader.Add(name, v)
+			}
+		}
+	}
+
+	client := &http.Client{Transport: transport}
+	if c.FollowRedirects != nil && !*c.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client, nil
+}
+
+// build returns the crypto/tls configuration of a TLS config.
+func (t PromTLSConfig) build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if t.MinVersion != "" {
+		cfg.MinVersion = tlsVersions[t.MinVersion]
+	}
+
+	ca, err := inlineOrFile(t.CA, t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA: %w", err)
+	}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no CA certificates found")
+		}
+		cfg.RootCAs = pool
+	}
+
+	cert, err := inlineOrFile(t.Cert, t.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client certificate: %w", err)
+	}
+	key, err := inlineOrFile(t.Key, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client key: %w", err)
+	}
+	if len(cert) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+func inlineOrFile(value, file string) ([]byte, error) {
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return []byte(value), nil
+}
+
+// matchesNoProxy reports whether host is excluded from proxying by a comma
+// separated list of hosts, domains (matching subdomains too), IPs and CIDRs.
+func matchesNoProxy(host, noProxy string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		host := strings.ToLower(host)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRequestHeaders sets the authentication and custom headers of an HTTP
+// config. Secrets in files are read on every request, so rotated secrets are
+// picked up.
+func (c *PromHTTPConfig) setRequestHeaders(req *http.Request) error {
+	switch {
+	case c.BasicAuth != nil:
+		password, err := secret(c.BasicAuth.Password, c.BasicAuth.PasswordFile)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(c.BasicAuth.Username, password)
+	case c.Authorization != nil:
+		credentials, err := secret(c.Authorization.Credentials, c.Authorization.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", orDefault(c.Authorization.Type, "Bearer")+" "+credentials)
+	case c.BearerToken != "" || c.BearerTokenFile != "":
+		token, err := secret(c.BearerToken, c.BearerTokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	for name, h := range c.HTTPHeaders {
+		req.Header.Del(name)
+		for _, v := range h.Values {
+			req.Header.Add(name, v)
+		}
+		for _, v := range h.Secrets {
+			req.Header.Add(name, v)
+		}
+		for _, f := range h.Files {
+			v, err := secret("", f)
+			if err != nil {
+				return err
+			}
+			req.Header.Add(name, v)
+		}
+	}
+	return nil
+}
+
+// secret returns value, or the trimmed content of file if it is set.
+func secret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading secret: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// parseHeaderFlag parses a `Name: value` header given on the command line.
+func parseHeaderFlag(s string) (string, string, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid header %q, expected 'Name: value'", s)
+	}
+	return http.CanonicalHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// httpFlags are the command line flags for fetching targets over HTTP. They
+// override the corresponding http_config settings of every target.
+type httpFlags struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	BearerTokenFile    string
+	ProxyURL           string
+}
+
+// register adds the flags to fs.
+func (f *httpFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.CAFile, "ca-file", "", "CA certificate file to verify targets with")
+	fs.StringVar(&f.CertFile, "cert-file", "", "Client certificate file for TLS client authentication")
+	fs.StringVar(&f.KeyFile, "key-file", "", "Client key file for TLS client authentication")
+	fs.BoolVar(&f.InsecureSkipVerify, "insecure-skip-verify", false, "Skip verifying the certificates of targets")
+	fs.StringVar(&f.BearerTokenFile, "bearer-token-file", "", "File holding a bearer token sent to targets")
+	fs.StringVar(&f.ProxyURL, "proxy-url", "", "Proxy URL to reach targets through")
+}
+
+// apply sets the flags given on the HTTP configs of targets, which may be
+// shared between targets, and validates the result.
+func (f httpFlags) apply(targets []ScrapeTarget) error {
+	if (f.CertFile != "") != (f.KeyFile != "") {
+		return errors.New("--cert-file and --key-file must be given together")
+	}
+	seen := make(map[*PromScrapeConfig]bool)
+	for _, t := range targets {
+		if seen[t.config] {
+			continue
+		}
+		seen[t.config] = true
+		c := &t.config.PromHTTPConfig
+		if f.CAFile != "" {
+			c.TLSConfig.CA, c.TLSConfig.CAFile = "", f.CAFile
+		}
+		if f.CertFile != "" {
+			c.TLSConfig.Cert, c.TLSConfig.CertFile = "", f.CertFile
+			c.TLSConfig.Key, c.TLSConfig.KeyFile = "", f.KeyFile
+		}
+		if f.InsecureSkipVerify {
+			c.TLSConfig.InsecureSkipVerify = true
+		}
+		if f.BearerTokenFile != "" {
+			c.BasicAuth, c.Authorization, c.BearerToken = nil, nil, ""
+			c.BearerTokenFile = f.BearerTokenFile
+		}
+		if f.ProxyURL != "" {
+			c.ProxyURL, c.ProxyFromEnvironment = f.ProxyURL, false
+		}
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	yaml "go.yaml.in/yaml/v2"
+)
+
+const testScrape = "# TYPE up gauge\nup 1\n"
+
+// writeClientCert writes a self-signed client certificate and its key as PEM
+// files and returns the certificate.
+func writeClientCert(t *testing.T, certFile, keyFile string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "scrapecli"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	writeFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return cert
+}
+
+func TestRunTargetsTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert := writeClientCert(t, filepath.Join(dir, "tls/client.crt"), filepath.Join(dir, "tls/client.key"))
+
+	var gotHeaders http.Header
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		_, _ = w.Write([]byte(testScrape))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	writeFile(t, filepath.Join(dir, "tls/ca.crt"), string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+	writeFile(t, filepath.Join(dir, "tenant"), "team-a\n")
+	addr := srv.Listener.Addr().String()
+
+	writeFile(t, filepath.Join(dir, "prometheus.yml"), `
+scrape_configs:
+  - job_name: mtls
+    scheme: https
+    tls_config:
+      ca_file: tls/ca.crt
+      cert_file: tls/client.crt
+      key_file: tls/client.key
+      server_name: example.com
+    http_headers:
+      X-Scope-OrgID:
+        files: [tenant]
+      X-Env:
+        values: [prod]
+    static_configs:
+      - targets: ['`+addr+`']
+  - job_name: insecure
+    scheme: https
+    tls_config:
+      insecure_skip_verify: true
+      cert_file: tls/client.crt
+      key_file: tls/client.key
+    static_configs:
+      - targets: ['`+addr+`']
+  - job_name: no_client_cert
+    scheme: https
+    tls_config:
+      ca_file: tls/ca.crt
+      server_name: example.com
+    static_configs:
+      - targets: ['`+addr+`']
+  - job_name: unknown_ca
+    scheme: https
+    static_configs:
+      - targets: ['`+addr+`']
+`)
+
+	var out bytes.Buffer
+	require.NoError(t, runTargets([]string{"--config", filepath.Join(dir, "prometheus.yml"), "-o", "json", "--workers", "1",
+		"-H", "X-Env: staging", "-H", "X-Request-Source: cli"}, &out))
+	var results []TargetSummary
+	require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	require.Len(t, results, 4)
+	byJob := make(map[string]TargetSummary)
+	for _, r := range results {
+		byJob[r.Job] = r
+	}
+
+	require.Empty(t, byJob["insecure"].Error)
+	require.Empty(t, byJob["mtls"].Error)
+	require.Len(t, byJob["mtls"].Summary.Metrics, 1)
+	require.NotEmpty(t, byJob["no_client_cert"].Error)
+	require.Contains(t, byJob["unknown_ca"].Error, "certificate")
+
+	// Workers scrape one at a time in order, so the last successful scrape
+	// was the mtls job. Command line headers replace configured ones.
+	require.Equal(t, "team-a", gotHeaders.Get("X-Scope-OrgID"))
+	require.Equal(t, []string{"staging"}, gotHeaders.Values("X-Env"))
+	require.Equal(t, "cli", gotHeaders.Get("X-Request-Source"))
+}
+
+func TestRunTargetsHTTPFlags(t *testing.T) {
+	dir := t.TempDir()
+	clientCert := writeClientCert(t, filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testScrape))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	writeFile(t, filepath.Join(dir, "ca.crt"), string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		_, _ = w.Write([]byte(testScrape))
+	}))
+	defer proxy.Close()
+
+	config := filepath.Join(dir, "prometheus.yml")
+	writeFile(t, config, `
+scrape_configs:
+  - job_name: tls
+    scheme: https
+    static_configs:
+      - targets: ['`+srv.Listener.Addr().String()+`']
+`)
+	plain := filepath.Join(dir, "plain.yml")
+	writeFile(t, plain, `
+scrape_configs:
+  - job_name: plain
+    static_configs:
+      - targets: ['node.example:9100']
+`)
+	run := func(args ...string) TargetSummary {
+		var out bytes.Buffer
+		require.NoError(t, runTargets(append([]string{"-o", "json"}, args...), &out))
+		var results []TargetSummary
+		require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+		require.Len(t, results, 1)
+		return results[0]
+	}
+
+	require.NotEmpty(t, run("--config", config).Error)
+	require.NotEmpty(t, run("--config", config, "--ca-file", filepath.Join(dir, "ca.crt")).Error, "the client certificate is missing")
+	cert := []string{"--config", config, "--cert-file", filepath.Join(dir, "client.crt"), "--key-file", filepath.Join(dir, "client.key")}
+	require.Empty(t, run(append(cert, "--ca-file", filepath.Join(dir, "ca.crt"))...).Error)
+	require.Empty(t, run(append(cert, "--insecure-skip-verify")...).Error)
+
+	// Requests go through the proxy, which answers for the target
+	require.Empty(t, run("--config", plain, "--proxy-url", proxy.URL).Error)
+	require.Equal(t, []string{"http://node.example:9100/metrics"}, proxied)
+
+	var out bytes.Buffer
+	require.Error(t, runTargets([]string{"--config", config, "--cert-file", filepath.Join(dir, "client.crt")}, &out))
+}
+
+func TestScrapeProxy(t *testing.T) {
+	var proxied, headers []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		headers = append(headers, r.Header.Get("X-Proxy-Test"))
+		_, _ = w.Write([]byte(testScrape))
+	}))
+	defer proxy.Close()
+
+	var cfg PromHTTPConfig
+	require.NoError(t, yaml.Unmarshal([]byte("proxy_url: "+proxy.URL+"\nno_proxy: localhost,.internal,10.0.0.0/8\nhttp_headers:\n  X-Proxy-Test:\n    secrets: ['yes']\n"), &cfg))
+	require.NoError(t, cfg.validate())
+	client, err := newScrapeClient(&cfg)
+	require.NoError(t, err)
+	sc := &PromScrapeConfig{PromHTTPConfig: cfg}
+
+	s, err := scrapeTarget(t.Context(), client, ScrapeTarget{URL: "http://node.example:9100/metrics", config: sc}, nil)
+	require.NoError(t, err)
+	require.Len(t, s.Metrics, 1)
+	require.Equal(t, []string{"http://node.example:9100/metrics"}, proxied)
+	require.Equal(t, []string{"yes"}, headers)
+
+	// Hosts excluded by no_proxy are connected to directly, where nothing listens
+	_, err = scrapeTarget(t.Context(), client, ScrapeTarget{URL: "http://localhost:1/metrics", config: sc}, nil)
+	require.Error(t, err)
+	require.Len(t, proxied, 1)
+}
+
+func TestScrapeFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/metrics", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(testScrape))
+	}))
+	defer srv.Close()
+
+	follow := false
+	for _, cfg := range []PromHTTPConfig{{}, {FollowRedirects: &follow}} {
+		client, err := newScrapeClient(&cfg)
+		require.NoError(t, err)
+		_, err = scrapeTarget(t.Context(), client, ScrapeTarget{URL: srv.URL + "/old", config: &PromScrapeConfig{PromHTTPConfig: cfg}}, nil)
+		if cfg.FollowRedirects == nil {
+			require.NoError(t, err)
+		} else {
+			require.ErrorContains(t, err, "302")
+		}
+	}
+}
+
+func TestPromHTTPConfigValidate(t *testing.T) {
+	for _, src := range []string{
+		"basic_auth: {username: a, password: b, password_file: c}",
+		"basic_auth: {username: a}\nbearer_token: t",
+		"authorization: {credentials: a, credentials_file: b}",
+		"authorization: {type: Basic, credentials: a}",
+		"bearer_token: a\nbearer_token_file: b",
+		"tls_config: {ca: a, ca_file: b}",
+		"tls_config: {cert_file: a}",
+		"tls_config: {min_version: TLS14}",
+		"proxy_url: http://proxy\nproxy_from_environment: true",
+		"no_proxy: localhost",
+		"proxy_url: '://bad'",
+	} {
+		var cfg PromHTTPConfig
+		require.NoError(t, yaml.Unmarshal([]byte(src), &cfg), src)
+		require.Error(t, cfg.validate(), src)
+	}
+
+	var cfg PromHTTPConfig
+	require.NoError(t, yaml.Unmarshal([]byte("authorization: {credentials_file: token}\ntls_config: {cert_file: c, key_file: k, min_version: TLS13}"), &cfg))
+	require.NoError(t, cfg.validate())
+}
+
+func TestMatchesNoProxy(t *testing.T) {
+	noProxy := "localhost, .internal,example.com,10.0.0.0/8"
+	require.True(t, matchesNoProxy("localhost", noProxy))
+	require.True(t, matchesNoProxy("db.internal", noProxy))
+	require.True(t, matchesNoProxy("EXAMPLE.com", noProxy))
+	require.True(t, matchesNoProxy("api.example.com", noProxy))
+	require.True(t, matchesNoProxy("10.1.2.3", noProxy))
+	require.False(t, matchesNoProxy("notexample.com", noProxy))
+	require.False(t, matchesNoProxy("192.168.0.1", noProxy))
+	require.False(t, matchesNoProxy("internal.example.org", ""))
+}
+
+func TestParseHeaderFlag(t *testing.T) {
+	name, value, err := parseHeaderFlag("x-scope-orgid:  team-a ")
+	require.NoError(t, err)
+	require.Equal(t, "X-Scope-Orgid", name)
+	require.Equal(t, "team-a", value)
+
+	for _, s := range []string{"novalue", ": value", "bad name: v"} {
+		_, _, err := parseHeaderFlag(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// maxLongestLabelValues is the number of series with the longest label values
+// kept in the summary.
+const maxLongestLabelValues = 10
+
+// labelPairOverhead is the number of bytes a label pair takes in the text
+// format besides its name and value: `="",`.
+const labelPairOverhead = len(`="",`)
+
+// LabelLengthStats describes the lengths (in bytes) of a label's values across
+// all series of a scrape and the bytes spent on the label.
+type LabelLengthStats struct {
+	Min int     `json:"min"`
+	Avg float64 `json:"avg"`
+	P99 int     `json:"p99"`
+	Max int     `json:"max"`
+	// Bytes is the size of the label's pairs across all series, i.e. name,
+	// value, quotes and separators, ignoring escaping.
+	Bytes int64 `json:"bytes"`
+}
+
+// LongLabelValue is a series with one of the longest label values of a
+// scrape.
+type LongLabelValue struct {
+	Series string `json:"series"`
+	Label  string `json:"label"`
+	Length int    `json:"length"`
+}
+
+// AnalyzeLabelLengths returns the value length statistics of every label and
+// the series with the longest label values. Every bucket of a histogram and
+// quantile of a summary counts as a series, as do their _sum and _count. Each
+// label set is listed at most once among the longest values, with its longest
+// value.
+func AnalyzeLabelLengths(mfs []*dto.MetricFamily) (map[string]LabelLengthStats, []LongLabelValue) {
+	// Number of series per value length, per label
+	lengths := make(map[string]map[int]int)
+	add := func(label string, length, series int) {
+		if _, ok := lengths[label]; !ok {
+			lengths[label] = make(map[int]int)
+		}
+		lengths[label][length] += series
+	}
+
+	var longest []LongLabelValue
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			series := 1
+			switch mf.GetType() {
+			case dto.MetricType_HISTOGRAM:
+				buckets := m.GetHistogram().GetBucket()
+				series = len(buckets) + 2
+				for _, b := range buckets {
+					add("le", len(formatValue(b.GetUpperBound())), 1)
+				}
+			case dto.MetricType_SUMMARY:
+				quantiles := m.GetSummary().GetQuantile()
+				series = len(quantiles) + 2
+				for _, q := range quantiles {
+					add("quantile", len(formatValue(q.GetQuantile())), 1)
+				}
+			}
+
+			var long LongLabelValue
+			for _, lp := range m.Label {
+				add(lp.GetName(), len(lp.GetValue()), series)
+				if len(lp.GetValue()) > long.Length {
+					long = LongLabelValue{Label: lp.GetName(), Length: len(lp.GetValue())}
+				}
+			}
+			if long.Length > 0 {
+				long.Series = formatSeries(mf.GetName(), m.Label)
+				longest = append(longest, long)
+			}
+		}
+	}
+
+	stats := make(map[string]LabelLengthStats, len(lengths))
+	for label, counts := range lengths {
+		stats[label] = lengthStats(label, counts)
+	}
+
+	sort.SliceStable(longest, func(i, j int) bool {
+		if longest[i].Length == longest[j].Length {
+			return longest[i].Series < longest[j].Series
+		}
+		return longest[i].Length > longest[j].Length
+	})
+	return stats, longest[:min(len(longest), maxLongestLabelValues)]
+}
+
+// lengthStats computes the statistics of a label from the number of series per
+// value length.
+func lengthStats(label string, counts map[int]int) LabelLengthStats {
+	sorted := make([]int, 0, len(counts))
+	series, total := 0, 0
+	for length, n := range counts {
+		sorted = append(sorted, length)
+		series += n
+		total += length * n
+	}
+	sort.Ints(sorted)
+
+	s := LabelLengthStats{
+		Min:   sorted[0],
+		Avg:   math.Round(float64(total)/float64(series)*10) / 10,
+		Max:   sorted[len(sorted)-1],
+		Bytes: int64(total + series*(len(label)+labelPairOverhead)),
+	}
+	// The p99 is the smallest length at least 99% of the series don't exceed
+	rank := int(math.Ceil(0.99 * float64(series)))
+	seen := 0
+	for _, length := range sorted {
+		seen += counts[length]
+		if seen >= rank {
+			s.P99 = length
+			break
+		}
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeLabelLengths(t *testing.T) {
+	data := []byte(`# TYPE latency_seconds histogram
+latency_seconds_bucket{url="https://example.com/a",le="0.5"} 3
+latency_seconds_bucket{url="https://example.com/a",le="+Inf"} 4
+latency_seconds_sum{url="https://example.com/a"} 20
+latency_seconds_count{url="https://example.com/a"} 4
+# TYPE requests_total counter
+requests_total{url="/b",code="200"} 1
+requests_total{url="/",code="500"} 1
+# TYPE up gauge
+up 1
+`)
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+
+	stats, longest := AnalyzeLabelLengths(mfs)
+	require.Equal(t, map[string]LabelLengthStats{
+		// 4 histogram series with 21 bytes, one with 2 and one with 1
+		"url":  {Min: 1, Avg: 14.5, P99: 21, Max: 21, Bytes: 87 + 6*(3+4)},
+		"le":   {Min: 3, Avg: 3.5, P99: 4, Max: 4, Bytes: 7 + 2*(2+4)},
+		"code": {Min: 3, Avg: 3, P99: 3, Max: 3, Bytes: 6 + 2*(4+4)},
+	}, stats)
+	require.Equal(t, []LongLabelValue{
+		{Series: `latency_seconds{url="https://example.com/a"}`, Label: "url", Length: 21},
+		{Series: `requests_total{url="/",code="500"}`, Label: "code", Length: 3},
+		{Series: `requests_total{url="/b",code="200"}`, Label: "code", Length: 3},
+	}, longest)
+}
+
+func TestLabelLengthStatsP99(t *testing.T) {
+	// 99 short values and a single long one: the p99 ignores the outlier
+	st := lengthStats("path", map[int]int{10: 99, 500: 1})
+	require.Equal(t, LabelLengthStats{Min: 10, Avg: 14.9, P99: 10, Max: 500, Bytes: 99*10 + 500 + 100*(4+4)}, st)
+
+	st = lengthStats("path", map[int]int{10: 98, 500: 2})
+	require.Equal(t, 500, st.P99)
+}
+
+func TestAnalyzeLabelLengthsLimit(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < maxLongestLabelValues+5; i++ {
+		fmt.Fprintf(&b, "up{instance=%q} 1\n", strings.Repeat("x", i+1))
+	}
+	mfs, err := decodeFamilies([]byte(b.String()))
+	require.NoError(t, err)
+
+	_, longest := AnalyzeLabelLengths(mfs)
+	require.Len(t, longest, maxLongestLabelValues)
+	require.Equal(t, maxLongestLabelValues+5, longest[0].Length)
+}
+
+func TestFormatLabelLengthsTerminal(t *testing.T) {
+	color.NoColor = true
+
+	s := ScrapeSummary{
+		Summary: MetricsSummary{
+			LabelCounts:      map[string]int{"url": 2, "code": 1, noneLabelKey: 1},
+			LabelValueCounts: map[string]int{"url": 3, "code": 2},
+			LabelLengths: map[string]LabelLengthStats{
+				"url":  {Min: 1, Avg: 14.5, P99: 21, Max: 21, Bytes: 129},
+				"code": {Min: 3, Avg: 3, P99: 3, Max: 3, Bytes: 22},
+			},
+			LongestLabelValues: []LongLabelValue{
+				{Series: `latency_seconds{url="https://example.com/a"}`, Label: "url", Length: 21},
+			},
+		},
+	}
+
+	out := FormatScrapeSummaryTerminal(s)
+	require.Contains(t, out, `Labels:
+  - url:    3 values, 2 metrics, 129 bytes, length 1–21 (avg 14.5, p99 21)
+  - code:   2 values, 1 metric,   22 bytes, length 3–3 (avg 3.0, p99 3)
+  - <none>: 1 metric
+  Longest values:
+     1. url (21 bytes): latency_seconds{url="https://example.com/a"}
+
+`)
+
+	// Long series are truncated to the width
+	out = FormatScrapeSummaryTerminalWithOptions(s, TerminalOptions{Width: 40})
+	require.Contains(t, out, "     1. url (21 bytes): latency_seconds…\n")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// defaultTopLabelValues is the number of values kept per label.
+const defaultTopLabelValues = 5
+
+// expandAllLabels expands every label in the terminal Label Values section.
+const expandAllLabels = "all"
+
+// LabelValueCount is a label value and the number of series it appears in.
+type LabelValueCount struct {
+	Value  string `json:"value"`
+	Series int    `json:"series"`
+}
+
+// LabelValues summarizes the values of a label: the values appearing in the
+// most series and the long tail of all others.
+type LabelValues struct {
+	Top []LabelValueCount `json:"top"`
+	// OtherValues is the number of values not in Top, OtherSeries the number
+	// of series they appear in.
+	OtherValues int `json:"other_values"`
+	OtherSeries int `json:"other_series"`
+}
+
+// TopLabelValues returns the k values of every label that appear in the most
+// series, across the scrape and per family. Every bucket of a histogram and
+// quantile of a summary counts as a series, as do their _sum and _count.
+func TopLabelValues(mfs []*dto.MetricFamily, k int) (map[string]LabelValues, map[string]map[string]LabelValues) {
+	if k <= 0 {
+		k = defaultTopLabelValues
+	}
+
+	global := make(map[string]map[string]int)
+	families := make(map[string]map[string]LabelValues, len(mfs))
+	for _, mf := range mfs {
+		family := make(map[string]map[string]int)
+		add := func(label, value string, series int) {
+			for _, counts := range []map[string]map[string]int{global, family} {
+				if _, ok := counts[label]; !ok {
+					counts[label] = make(map[string]int)
+				}
+				counts[label][value] += series
+			}
+		}
+		for _, m := range mf.Metric {
+			series := 1
+			switch mf.GetType() {
+			case dto.MetricType_HISTOGRAM:
+				buckets := m.GetHistogram().GetBucket()
+				series = len(buckets) + 2
+				for _, b := range buckets {
+					add("le", fmt.Sprintf("%g", b.GetUpperBound()), 1)
+				}
+			case dto.MetricType_SUMMARY:
+				quantiles := m.GetSummary().GetQuantile()
+				series = len(quantiles) + 2
+				for _, q := range quantiles {
+					add("quantile", fmt.Sprintf("%g", q.GetQuantile()), 1)
+				}
+			}
+			for _, lp := range m.Label {
+				add(lp.GetName(), lp.GetValue(), series)
+			}
+		}
+		if len(family) > 0 {
+			families[mf.GetName()] = topValues(family, k)
+		}
+	}
+	return topValues(global, k), families
+}
+
+// topValues keeps the k values with the most series per label, ties broken by
+// value, and sums up the others.
+func topValues(counts map[string]map[string]int, k int) map[string]LabelValues {
+	result := make(map[string]LabelValues, len(counts))
+	for label, values := range counts {
+		sorted := make([]LabelValueCount, 0, len(values))
+		for v, n := range values {
+			sorted = append(sorted, LabelValueCount{Value: v, Series: n})
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].Series == sorted[j].Series {
+				return sorted[i].Value < sorted[j].Value
+			}
+			return sorted[i].Series > sorted[j].Series
+		})
+		lv := LabelValues{Top: sorted[:min(k, len(sorted))]}
+		for _, c := range sorted[len(lv.Top):] {
+			lv.OtherValues++
+			lv.OtherSeries += c.Series
+		}
+		result[label] = lv
+	}
+	return result
+}
+
+// setTopLabelValues attaches the top label values to the summary and its
+// families.
+func (s *ScrapeSummary) setTopLabelValues(mfs []*dto.MetricFamily, k int) {
+	global, families := TopLabelValues(mfs, k)
+	s.Summary.LabelTopValues = global
+	for i := range s.Metrics {
+		s.Metrics[i].LabelTopValues = families[s.Metrics[i].Name]
+	}
+}
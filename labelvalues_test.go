@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopLabelValues(t *testing.T) {
+	data := []byte(`# TYPE latency_seconds histogram
+latency_seconds_bucket{handler="/a",le="1"} 3
+latency_seconds_bucket{handler="/a",le="+Inf"} 4
+latency_seconds_sum{handler="/a"} 20
+latency_seconds_count{handler="/a"} 4
+latency_seconds_bucket{handler="/b",le="1"} 0
+latency_seconds_bucket{handler="/b",le="+Inf"} 2
+latency_seconds_sum{handler="/b"} 5
+latency_seconds_count{handler="/b"} 2
+# TYPE requests_total counter
+requests_total{handler="/a",code="200"} 1
+requests_total{handler="/b",code="200"} 1
+requests_total{handler="/c",code="200"} 1
+requests_total{handler="/d",code="500"} 1
+# TYPE up gauge
+up 1
+`)
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+
+	global, families := TopLabelValues(mfs, 2)
+	require.Equal(t, map[string]LabelValues{
+		// Every bucket, _sum and _count of a histogram is a series
+		"handler": {Top: []LabelValueCount{{Value: "/a", Series: 5}, {Value: "/b", Series: 5}}, OtherValues: 2, OtherSeries: 2},
+		"le":      {Top: []LabelValueCount{{Value: "+Inf", Series: 2}, {Value: "1", Series: 2}}},
+		"code":    {Top: []LabelValueCount{{Value: "200", Series: 3}, {Value: "500", Series: 1}}},
+	}, global)
+
+	require.Len(t, families, 2)
+	require.Equal(t, LabelValues{
+		Top:         []LabelValueCount{{Value: "/a", Series: 1}, {Value: "/b", Series: 1}},
+		OtherValues: 2, OtherSeries: 2,
+	}, families["requests_total"]["handler"])
+	require.Equal(t, LabelValues{
+		Top: []LabelValueCount{{Value: "/a", Series: 4}, {Value: "/b", Series: 4}},
+	}, families["latency_seconds"]["handler"])
+	require.NotContains(t, families, "up")
+
+	// The default keeps defaultTopLabelValues values
+	global, _ = TopLabelValues(mfs, 0)
+	require.Len(t, global["handler"].Top, 4)
+}
+
+func TestFormatLabelValuesTerminal(t *testing.T) {
+	color.NoColor = true
+
+	s := ScrapeSummary{
+		Summary: MetricsSummary{
+			LabelCounts:      map[string]int{"handler": 2, "job": 1},
+			LabelValueCounts: map[string]int{"handler": 4, "job": 1},
+			LabelTopValues: map[string]LabelValues{
+				"handler": {Top: []LabelValueCount{{Value: "/a", Series: 5}, {Value: "/b", Series: 5}}, OtherValues: 2, OtherSeries: 2},
+				"job":     {Top: []LabelValueCount{{Value: "node", Series: 1}}},
+			},
+		},
+		Metrics: []MetricSummary{
+			{Name: "latency_seconds", Type: "HISTOGRAM", Labels: []string{"handler"}, LabelTopValues: map[string]LabelValues{
+				"handler": {Top: []LabelValueCount{{Value: "/a", Series: 4}, {Value: "/b", Series: 4}}},
+			}},
+			{Name: "requests_total", Type: "COUNTER", Labels: []string{"handler", "job"}, LabelTopValues: map[string]LabelValues{
+				"handler": {Top: []LabelValueCount{{Value: "/a", Series: 1}, {Value: "/b", Series: 1}}, OtherValues: 1, OtherSeries: 1},
+				"job":     {Top: []LabelValueCount{{Value: "node", Series: 1}}},
+			}},
+		},
+	}
+
+	// Collapsed: only labels with several values, without families
+	out := FormatScrapeSummaryTerminal(s)
+	require.Contains(t, out, `Label Values (series per value):
+  - handler: /a (5), /b (5), +2 more values (2)
+  Use --expand-label NAME (or all) to show the values per family.
+`)
+	require.NotContains(t, out, "  - job: node")
+
+	// Expanded labels are listed per family, even with a single value
+	out = FormatScrapeSummaryTerminalWithOptions(s, TerminalOptions{ExpandLabels: []string{"handler", "job"}})
+	require.Contains(t, out, `Label Values (series per value):
+  - handler: /a (5), /b (5), +2 more values (2)
+      latency_seconds: /a (4), /b (4)
+      requests_total: /a (1), /b (1), +1 more value (1)
+  - job: node (1)
+      requests_total: node (1)
+
+`)
+
+	out = FormatScrapeSummaryTerminalWithOptions(s, TerminalOptions{ExpandLabels: []string{expandAllLabels}})
+	require.Contains(t, out, "      requests_total: node (1)\n")
+}
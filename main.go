@@ -7,15 +7,154 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 )
 
 func main() {
+	// Subcommands are dispatched on the first argument; without one the scrape
+	// on stdin is summarized.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "redact":
+			if err := runRedact(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "diff":
+			if err := runDiff(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "analyze":
+			if err := runAnalyze(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "targets":
+			if err := runTargets(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "serve":
+			if err := runServe(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "serve-api":
+			if err := runServeAPI(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "schema":
+			if err := runSchema(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "validate":
+			if err := runValidate(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Add a command-line flag to select output format: json or terminal
 	var outputFormat string
-	flag.StringVar(&outputFormat, "output-format", "terminal", "Output format: json or terminal")
+	flag.StringVar(&outputFormat, "output-format", "terminal", "Output format: terminal, json, ndjson, markdown, html, svg, folded, csv, tsv, prom or openmetrics")
 	flag.StringVar(&outputFormat, "o", "terminal", "Shorthand for --output-format")
+	// Table exported by the csv and tsv output formats
+	var section string
+	flag.StringVar(&section, "section", sectionMetrics, "Table to export with csv/tsv output: metrics, labels or types")
+	// Records of the ndjson output
+	var level string
+	flag.StringVar(&level, "level", ndjsonLevelFamily, "Records of ndjson output: family (one per metric family) or series (one per sample line, streamed)")
+	// Title of the html report
+	var title string
+	flag.StringVar(&title, "title", "Prometheus scrape report", "Title of the html report")
+	// Value the svg treemap and folded stacks are sized by
+	var sizeBy string
+	flag.StringVar(&sizeBy, "size-by", sizeBySeries, "Size svg and folded output by series or bytes")
+	// Rollup by name prefix depth and custom regex groups
+	var rollupDepth int
+	flag.IntVar(&rollupDepth, "rollup-depth", defaultRollupDepth, "Number of name prefix levels in the rollup section")
+	var rollupRules []RollupRule
+	flag.Func("rollup-group", "Custom rollup group as name=regex (repeatable), checked before prefix grouping", func(s string) error {
+		rule, err := ParseRollupRule(s)
+		if err != nil {
+			return err
+		}
+		rollupRules = append(rollupRules, rule)
+		return nil
+	})
+	// Histogram bucket analysis: flag threshold and target bucket count
+	var bucketThreshold, reduceBuckets int
+	flag.IntVar(&bucketThreshold, "bucket-threshold", defaultBucketThreshold, "Flag histograms with more buckets than this")
+	flag.IntVar(&reduceBuckets, "reduce-buckets", defaultReduceBuckets, "Bucket count to compute the savings of reducing histograms for")
+	// Bucket count of the classic histograms summaries are compared to
+	var summaryBuckets int
+	flag.IntVar(&summaryBuckets, "summary-buckets", defaultSummaryBuckets, "Bucket count of the classic histogram summaries are compared to")
+	// Label values kept per label and labels broken down per family
+	var topValues int
+	flag.IntVar(&topValues, "top-values", defaultTopLabelValues, "Number of values with the most series listed per label")
+	var expandLabels []string
+	flag.Func("expand-label", "Label whose top values are listed per family in the terminal output, or all (repeatable)", func(s string) error {
+		expandLabels = append(expandLabels, s)
+		return nil
+	})
+	// Colored output: auto (terminal without NO_COLOR), always or never
+	var colorMode string
+	flag.StringVar(&colorMode, "color", colorAuto, "Colored output: auto, always or never (auto respects NO_COLOR)")
+	// Optional PromQL vector selector to restrict the analysis to a subset of series
+	var selectExpr string
+	flag.StringVar(&selectExpr, "select", "", `PromQL vector selector restricting the analyzed series, e.g. '{__name__=~"http_.*"}'`)
 	flag.Parse()
 
+	if err := configureColor(strings.ToLower(colorMode), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var selector Selector
+	if selectExpr != "" {
+		var err error
+		selector, err = ParseSelector(selectExpr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error parsing --select: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	of := strings.ToLower(outputFormat)
+
+	// Series are streamed from stdin without summarizing the scrape
+	if of == "ndjson" {
+		switch strings.ToLower(level) {
+		case ndjsonLevelFamily:
+		case ndjsonLevelSeries:
+			in, _, err := decompressReader(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reading stdin: %v\n", err)
+				os.Exit(1)
+			}
+			if err := WriteSeriesNDJSON(in, os.Stdout, selector); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing ndjson: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "error: invalid --level %q (expected %s or %s)\n", level, ndjsonLevelFamily, ndjsonLevelSeries)
+			os.Exit(1)
+		}
+	}
+
 	// Read entire Prometheus scrape from stdin
 	data, err := io.ReadAll(os.Stdin)
 	if err != nil {
@@ -23,20 +162,111 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Compressed input (e.g. curl without --compressed) is decompressed first
+	raw := data
+	data, encoding, err := decompressScrape(raw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading stdin: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Exposition formats re-encode the (selected) scrape instead of summarizing it
+	if of == exposeFormatProm || of == exposeFormatOpenMetrics {
+		mfs, err := decodeFamilies(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error parsing scrape: %v\n", err)
+			os.Exit(1)
+		}
+		if selector != nil {
+			mfs, err = filterFamilies(mfs, selector)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error selecting series: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		// OpenMetrics counters are exposed with their sample names
+		if of == exposeFormatProm && isOpenMetrics(data) {
+			mfs = withCounterTotals(mfs)
+		}
+		if err := EncodeFamilies(os.Stdout, mfs, of); err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding scrape: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Filter before any analysis so every section reflects only the selection
+	decompressedBytes := int64(len(data))
+	if selector != nil {
+		data, err = SelectScrape(data, selector)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error selecting series: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	summary := SummarizeScrape(data)
+	if encoding != "" {
+		summary.Summary.setCompression(encoding, int64(len(raw)), decompressedBytes)
+	}
+	if rollupDepth != defaultRollupDepth || len(rollupRules) > 0 {
+		summary.Rollup = Rollup(summary.Metrics, RollupOptions{Depth: rollupDepth, Rules: rollupRules})
+	}
+	if bucketThreshold != defaultBucketThreshold || reduceBuckets != defaultReduceBuckets || summaryBuckets != defaultSummaryBuckets {
+		if mfs, err := decodeFamilies(data); err == nil {
+			summary.Histograms = AnalyzeHistograms(mfs, HistogramOptions{Threshold: bucketThreshold, ReduceTo: reduceBuckets})
+			summary.Summaries = AnalyzeSummaries(mfs, summaryBuckets)
+		}
+	}
 
-	of := strings.ToLower(outputFormat)
-	if of == "json" {
-		b, err := json.MarshalIndent(summary, "", "  ")
+	if topValues != defaultTopLabelValues {
+		if mfs, err := decodeFamilies(data); err == nil {
+			summary.setTopLabelValues(mfs, topValues)
+		}
+	}
+
+	switch of {
+	case "json":
+		b, err := json.MarshalIndent(newSummaryOutput(summary, "stdin", time.Now()), "", "  ")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error marshaling json: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Println(string(b))
-		return
+	case "ndjson":
+		if err := WriteFamiliesNDJSON(os.Stdout, summary); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing ndjson: %v\n", err)
+			os.Exit(1)
+		}
+	case "markdown", "md":
+		fmt.Print(FormatScrapeSummaryMarkdown(summary))
+	case "html":
+		if err := FormatScrapeSummaryHTML(os.Stdout, summary, title); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing html: %v\n", err)
+			os.Exit(1)
+		}
+	case "svg":
+		if err := FormatScrapeSummarySVG(os.Stdout, summary, strings.ToLower(sizeBy)); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing svg: %v\n", err)
+			os.Exit(1)
+		}
+	case "folded":
+		if err := FormatFoldedStacks(os.Stdout, summary, strings.ToLower(sizeBy)); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing folded stacks: %v\n", err)
+			os.Exit(1)
+		}
+	case "csv", "tsv":
+		comma := ','
+		if of == "tsv" {
+			comma = '\t'
+		}
+		if err := FormatScrapeSummaryCSV(os.Stdout, summary, strings.ToLower(section), comma); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing %s: %v\n", of, err)
+			os.Exit(1)
+		}
+	default:
+		// Default: terminal human-readable output, adapted to the terminal width
+		out := FormatScrapeSummaryTerminalWithOptions(summary, TerminalOptions{Width: terminalWidth(os.Stdout), ExpandLabels: expandLabels})
+		fmt.Print(out)
 	}
-
-	// Default: terminal human-readable output
-	out := FormatScrapeSummaryTerminal(summary)
-	fmt.Print(out)
 }
@@ -4,11 +4,28 @@ package main
 
 // MetricsSummary holds a summary of the size and is JSON-serializable.
 type MetricsSummary struct {
-	Bytes            int64              `json:"bytes"`
+	Bytes int64 `json:"bytes"`
+	// Encoding is the compression the scrape was received in, if any.
+	// CompressedBytes is its compressed size and CompressionRatio the ratio of
+	// the decompressed size to CompressedBytes. Both describe the whole
+	// scrape, even if Bytes only counts the selected series.
+	Encoding         string  `json:"encoding,omitempty"`
+	CompressedBytes  int64   `json:"compressed_bytes,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	// GzipBytes is the size of the (decompressed) scrape compressed with
+	// gzip, as transferred by most exporters.
+	GzipBytes        int64              `json:"gzip_bytes,omitempty"`
 	TopCardinalities []CardinalityEntry `json:"top_cardinalities"`
 	TypesCount       map[string]int     `json:"type_counts,omitempty"`
 	LabelCounts      map[string]int     `json:"label_counts,omitempty"`
 	LabelValueCounts map[string]int     `json:"label_value_counts,omitempty"`
+	// LabelTopValues holds the values of every label appearing in the most
+	// series.
+	LabelTopValues map[string]LabelValues `json:"label_top_values,omitempty"`
+	// LabelLengths holds the value length statistics and bytes of every
+	// label, LongestLabelValues the series with the longest label values.
+	LabelLengths       map[string]LabelLengthStats `json:"label_lengths,omitempty"`
+	LongestLabelValues []LongLabelValue            `json:"longest_label_values,omitempty"`
 }
 
 // CardinalityEntry is a small struct holding metric name and its cardinality.
@@ -24,13 +41,35 @@ type MetricSummary struct {
 	Description string   `json:"description"`
 	Cardinality int      `json:"cardinality"`
 	Labels      []string `json:"labels"`
-	Size        int64    `json:"size_bytes"`
+	// LabelValueCounts holds the number of distinct values per label within
+	// this metric family.
+	LabelValueCounts map[string]int `json:"label_value_counts,omitempty"`
+	// LabelTopValues holds the values of every label appearing in the most
+	// series of this metric family.
+	LabelTopValues map[string]LabelValues `json:"label_top_values,omitempty"`
+	Size           int64                  `json:"size_bytes"`
+	// GzipBytes is the gzip-compressed size of this family's lines on their
+	// own. GzipMarginalBytes is how much the compressed scrape grows because
+	// of them; it is small for families that compress well, e.g. because
+	// labels repeat. It is omitted for scrapes too large to compute it.
+	GzipBytes         int64 `json:"gzip_bytes"`
+	GzipMarginalBytes int64 `json:"gzip_marginal_bytes,omitempty"`
+	// Exemplars summarizes the exemplars attached to this family's samples.
+	Exemplars *ExemplarSummary `json:"exemplars,omitempty"`
 }
 
 // ScrapeSummary wraps different summaries about a scrape.
 type ScrapeSummary struct {
 	Summary MetricsSummary  `json:"summary"`
 	Metrics []MetricSummary `json:"metrics"`
+	// Rollup groups the metrics hierarchically by name prefix.
+	Rollup []RollupGroup `json:"rollup,omitempty"`
+	// ValueFindings lists sample values failing a sanity check.
+	ValueFindings []ValueFinding `json:"value_findings,omitempty"`
+	// Histograms analyzes the bucket layout of every histogram family.
+	Histograms []HistogramAnalysis `json:"histograms,omitempty"`
+	// Summaries compares every summary family to equivalent histograms.
+	Summaries []SummaryAnalysis `json:"summaries,omitempty"`
 }
 
 // SummarizeSize takes the raw scrape bytes and returns a MetricsSummary.
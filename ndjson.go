@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	prommodel "github.com/prometheus/common/model"
+)
+
+// Levels of the ndjson output: one record per metric family or per series.
+const (
+	ndjsonLevelFamily = "family"
+	ndjsonLevelSeries = "series"
+)
+
+// SeriesRecord is a sample line of a scrape as written by the ndjson output
+// at series level.
+type SeriesRecord struct {
+	Family string `json:"family"`
+	Type   string `json:"type"`
+	// Name is the sample name; Suffix is what it adds to the family name,
+	// e.g. "bucket", "sum" or "count" for histograms.
+	Name   string            `json:"name"`
+	Suffix string            `json:"suffix,omitempty"`
+	Labels map[string]string `json:"labels"`
+	// Value is null for NaN and infinite values, which JSON can't represent.
+	// RawValue is the value as written.
+	Value    *float64 `json:"value"`
+	RawValue string   `json:"raw_value"`
+	// Timestamp is in milliseconds. Integers are taken as milliseconds as in
+	// the Prometheus text format, others (e.g. 1700000000.123) as seconds as
+	// in OpenMetrics. RawTimestamp is the timestamp as written.
+	Timestamp    *int64 `json:"timestamp,omitempty"`
+	RawTimestamp string `json:"raw_timestamp,omitempty"`
+	// Bytes is the length of the line including its newline; Line is its
+	// 1-based number.
+	Bytes int `json:"bytes"`
+	Line  int `json:"line"`
+}
+
+// WriteSeriesNDJSON reads a text scrape from r line by line and writes one
+// SeriesRecord per sample line as newline-delimited JSON, keeping only the
+// series matched by sel (if any). Only the declared types are kept in memory,
+// so scrapes of any size are streamed.
+func WriteSeriesNDJSON(r io.Reader, w io.Writer, sel Selector) error {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(1); err == nil && !textScrapeStart(head[0]) {
+		return errors.New("series level output requires a text scrape")
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	types := make(map[string]string)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			rec, ok, perr := seriesRecord(line, n, types)
+			if perr != nil {
+				return fmt.Errorf("line %d: %w", n, perr)
+			}
+			if ok && sel != nil {
+				if err := sel.checkSampleNames(rec.Family, rec.Name); err != nil {
+					return fmt.Errorf("line %d: %w", n, err)
+				}
+			}
+			if ok && (sel == nil || sel.Matches(recordLabels(rec))) {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// seriesRecord parses a line of a text scrape. TYPE lines are recorded in
+// types; it reports false for lines that aren't samples.
+func seriesRecord(line string, n int, types map[string]string) (SeriesRecord, bool, error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return SeriesRecord{}, false, nil
+	}
+	if strings.HasPrefix(trimmed, "#") {
+		fields := strings.Fields(trimmed)
+		if len(fields) >= 4 && fields[0] == "#" && fields[1] == "TYPE" {
+			name, rest := metadataName(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(trimmed[1:]), "TYPE")))
+			if name != "" {
+				types[name] = strings.ToLower(strings.TrimSpace(rest))
+			}
+		}
+		return SeriesRecord{}, false, nil
+	}
+
+	s, err := parseSampleLine(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return SeriesRecord{}, false, err
+	}
+	rec := SeriesRecord{
+		Family:   declaredFamily(types, s.Name),
+		Name:     s.Name,
+		Labels:   make(map[string]string, len(s.Labels)),
+		RawValue: s.Value,
+		Bytes:    len(line),
+		Line:     n,
+	}
+	rec.Type = types[rec.Family]
+	if rec.Type == "" {
+		rec.Type = "untyped"
+	}
+	if rec.Family != s.Name {
+		rec.Suffix = strings.TrimPrefix(s.Name[len(rec.Family):], "_")
+	}
+	for _, l := range s.Labels {
+		rec.Labels[l.Name] = l.Value
+	}
+
+	v, err := strconv.ParseFloat(s.Value, 64)
+	if err != nil {
+		return SeriesRecord{}, false, fmt.Errorf("invalid value %q", s.Value)
+	}
+	if !math.IsNaN(v) && !math.IsInf(v, 0) {
+		rec.Value = &v
+	}
+	if s.Timestamp != "" {
+		ts, err := parseTimestamp(s.Timestamp)
+		if err != nil {
+			return SeriesRecord{}, false, err
+		}
+		rec.Timestamp = &ts
+		rec.RawTimestamp = s.Timestamp
+	}
+	return rec, true, nil
+}
+
+// parseTimestamp returns a sample timestamp in milliseconds. Integers are
+// milliseconds (Prometheus text format), other numbers seconds (OpenMetrics).
+func parseTimestamp(raw string) (int64, error) {
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return ms, nil
+	}
+	sec, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(sec) || math.IsInf(sec, 0) {
+		return 0, fmt.Errorf("invalid timestamp %q", raw)
+	}
+	return int64(math.Round(sec * 1000)), nil
+}
+
+// recordLabels returns the labels a selector matches a record by. As for
+// other outputs, __name__ is the family name.
+func recordLabels(rec SeriesRecord) map[string]string {
+	labels := make(map[string]string, len(rec.Labels)+1)
+	for k, v := range rec.Labels {
+		labels[k] = v
+	}
+	labels[prommodel.MetricNameLabel] = rec.Family
+	return labels
+}
+
+// WriteFamiliesNDJSON writes one MetricSummary per metric family as
+// newline-delimited JSON.
+func WriteFamiliesNDJSON(w io.Writer, s ScrapeSummary) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	for _, m := range s.Metrics {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
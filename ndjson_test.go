@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readSeriesRecords(t *testing.T, out *bytes.Buffer) []SeriesRecord {
+	t.Helper()
+	var records []SeriesRecord
+	sc := bufio.NewScanner(out)
+	for sc.Scan() {
+		var rec SeriesRecord
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestWriteSeriesNDJSON(t *testing.T) {
+	scrape := "# HELP req_seconds Latency.\n" +
+		"# TYPE req_seconds histogram\n" +
+		"req_seconds_bucket{le=\"0.5\",path=\"/a\"} 3\n" +
+		"req_seconds_bucket{le=\"+Inf\",path=\"/a\"} 4 # {trace_id=\"x\"} 0.7\n" +
+		"req_seconds_sum{path=\"/a\"} 1.5\n" +
+		"req_seconds_count{path=\"/a\"} 4\n" +
+		"\n" +
+		"# TYPE temp gauge\n" +
+		"temp{room=\"a \\\"b\\\"\"} NaN 1700000000000\n" +
+		"undeclared_total 7"
+
+	var out bytes.Buffer
+	require.NoError(t, WriteSeriesNDJSON(strings.NewReader(scrape), &out, nil))
+	records := readSeriesRecords(t, &out)
+	require.Len(t, records, 6)
+
+	one, four := 1.5, 4.0
+	require.Equal(t, SeriesRecord{
+		Family: "req_seconds", Type: "histogram", Name: "req_seconds_bucket", Suffix: "bucket",
+		Labels: map[string]string{"le": "+Inf", "path": "/a"}, Value: &four, RawValue: "4",
+		Bytes: len("req_seconds_bucket{le=\"+Inf\",path=\"/a\"} 4 # {trace_id=\"x\"} 0.7\n"), Line: 4,
+	}, records[1])
+	require.Equal(t, "sum", records[2].Suffix)
+	require.Equal(t, &one, records[2].Value)
+	require.Equal(t, "count", records[3].Suffix)
+
+	temp := records[4]
+	require.Equal(t, "temp", temp.Family)
+	require.Equal(t, "gauge", temp.Type)
+	require.Empty(t, temp.Suffix)
+	require.Equal(t, map[string]string{"room": `a "b"`}, temp.Labels)
+	require.Nil(t, temp.Value)
+	require.Equal(t, "NaN", temp.RawValue)
+	require.Equal(t, int64(1700000000000), *temp.Timestamp)
+	require.Equal(t, 9, temp.Line)
+
+	// Without a trailing newline the last line is still a record
+	undeclared := records[5]
+	require.Equal(t, "undeclared_total", undeclared.Family)
+	require.Equal(t, "untyped", undeclared.Type)
+	require.Equal(t, len("undeclared_total 7"), undeclared.Bytes)
+	require.Equal(t, 10, undeclared.Line)
+}
+
+func TestWriteSeriesNDJSONSelect(t *testing.T) {
+	scrape := "# TYPE req_seconds histogram\n" +
+		"req_seconds_bucket{le=\"+Inf\",path=\"/a\"} 4\n" +
+		"req_seconds_count{path=\"/a\"} 4\n" +
+		"req_seconds_bucket{le=\"+Inf\",path=\"/b\"} 1\n" +
+		"req_seconds_count{path=\"/b\"} 1\n" +
+		"up 1\n"
+	sel, err := ParseSelector(`req_seconds{path="/a"}`)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, WriteSeriesNDJSON(strings.NewReader(scrape), &out, sel))
+	records := readSeriesRecords(t, &out)
+	require.Len(t, records, 2)
+	for _, rec := range records {
+		require.Equal(t, "/a", rec.Labels["path"])
+	}
+}
+
+func TestWriteSeriesNDJSONOpenMetricsTimestamps(t *testing.T) {
+	scrape := "# TYPE foo counter\n" +
+		"foo_total{a=\"1\"} 3 1700000000.123\n" +
+		"foo_total{a=\"2\"} 4 1.7e9\n" +
+		"# EOF\n"
+	var out bytes.Buffer
+	require.NoError(t, WriteSeriesNDJSON(strings.NewReader(scrape), &out, nil))
+	records := readSeriesRecords(t, &out)
+	require.Len(t, records, 2)
+	require.Equal(t, "foo", records[0].Family)
+	require.Equal(t, int64(1700000000123), *records[0].Timestamp)
+	require.Equal(t, "1700000000.123", records[0].RawTimestamp)
+	require.Equal(t, int64(1700000000000), *records[1].Timestamp)
+}
+
+func TestWriteSeriesNDJSONErrors(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteSeriesNDJSON(strings.NewReader("up 1\nup{ 1\n"), &out, nil)
+	require.ErrorContains(t, err, "line 2")
+
+	err = WriteSeriesNDJSON(strings.NewReader("up one\n"), &out, nil)
+	require.ErrorContains(t, err, `invalid value "one"`)
+
+	err = WriteSeriesNDJSON(strings.NewReader("up 1 soon\n"), &out, nil)
+	require.ErrorContains(t, err, `invalid timestamp "soon"`)
+
+	err = WriteSeriesNDJSON(bytes.NewReader([]byte{0x10, 0x0a, 0x02}), &out, nil)
+	require.ErrorContains(t, err, "text scrape")
+
+	out.Reset()
+	require.NoError(t, WriteSeriesNDJSON(strings.NewReader(""), &out, nil))
+	require.Empty(t, out.String())
+}
+
+func TestWriteFamiliesNDJSON(t *testing.T) {
+	s := SummarizeScrape([]byte("# TYPE a gauge\na{x=\"1\"} 1\na{x=\"2\"} 1\n# TYPE b counter\nb_total 1\n"))
+	var out bytes.Buffer
+	require.NoError(t, WriteFamiliesNDJSON(&out, s))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, len(s.Metrics))
+	for i, line := range lines {
+		var m MetricSummary
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		require.Equal(t, s.Metrics[i], m)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// openMetricsText holds a text scrape rewritten for the Prometheus text
+// parser, see normalizeOpenMetrics.
+type openMetricsText struct {
+	data []byte
+	// created holds the _created samples per family and label set key.
+	created map[string]map[string]float64
+	// OpenMetrics is set if the scrape used OpenMetrics sample names.
+	OpenMetrics bool
+}
+
+// normalizeOpenMetrics rewrites the sample names only OpenMetrics uses, so
+// that the Prometheus text parser attributes the samples to their declared
+// family: the `foo_total` samples of a counter `foo` are renamed to `foo`, and
+// the `_created` samples of counters, histograms and summaries are removed and
+// kept as created timestamps. The Prometheus text format of the Python client
+// exposes `_created` samples as gauges with their own TYPE line; these are
+// only folded if the scrape ends with `# EOF`. Other lines are kept as is.
+func normalizeOpenMetrics(data []byte) openMetricsText {
+	if !bytes.Contains(data, []byte("_total")) && !bytes.Contains(data, []byte("_created")) {
+		return openMetricsText{data: data}
+	}
+	eof := endsWithEOF(data)
+
+	types := make(map[string]string)
+	out := openMetricsText{created: make(map[string]map[string]float64)}
+	lines := strings.Split(string(data), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if rest, ok := strings.CutPrefix(trimmed, "# TYPE "); ok {
+			if name, typ := metadataName(strings.TrimSpace(rest)); name != "" {
+				types[name] = strings.ToLower(strings.TrimSpace(typ))
+			}
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			kept = append(kept, line)
+			continue
+		}
+		s, err := parseSampleLine(line)
+		if err != nil || !strings.HasPrefix(trimmed, s.Name) {
+			kept = append(kept, line)
+			continue
+		}
+		if family, ok := strings.CutSuffix(s.Name, "_total"); ok && types[family] == "counter" {
+			out.OpenMetrics = true
+			kept = append(kept, family+trimmed[len(s.Name):])
+			continue
+		}
+		if family, ok := strings.CutSuffix(s.Name, "_created"); ok && (eof || types[s.Name] == "") {
+			switch types[family] {
+			case "counter", "histogram", "summary":
+				out.OpenMetrics = true
+				if v, err := strconv.ParseFloat(s.Value, 64); err == nil {
+					if out.created[family] == nil {
+						out.created[family] = make(map[string]float64)
+					}
+					pairs := make([]*dto.LabelPair, 0, len(s.Labels))
+					for _, l := range s.Labels {
+						pairs = append(pairs, &dto.LabelPair{Name: &l.Name, Value: &l.Value})
+					}
+					out.created[family][sortedLabelsKey(pairs)] = v
+				}
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	out.data = []byte(strings.Join(kept, "\n"))
+	return out
+}
+
+// setCreated attaches the removed _created samples to the parsed families.
+func (t openMetricsText) setCreated(byName map[string]*dto.MetricFamily) {
+	for family, created := range t.created {
+		mf, ok := byName[family]
+		if !ok {
+			continue
+		}
+		for _, m := range mf.Metric {
+			v, ok := created[sortedLabelsKey(m.Label)]
+			if !ok {
+				continue
+			}
+			sec, frac := math.Modf(v)
+			ts := timestamppb.New(time.Unix(int64(sec), int64(frac*1e9)))
+			switch {
+			case m.Counter != nil:
+				m.Counter.CreatedTimestamp = ts
+			case m.Histogram != nil:
+				m.Histogram.CreatedTimestamp = ts
+			case m.Summary != nil:
+				m.Summary.CreatedTimestamp = ts
+			}
+		}
+	}
+}
+
+// isOpenMetrics reports whether data is an OpenMetrics text scrape, i.e. ends
+// with `# EOF` or uses OpenMetrics sample names.
+func isOpenMetrics(data []byte) bool {
+	return endsWithEOF(data) || normalizeOpenMetrics(data).OpenMetrics
+}
+
+// endsWithEOF reports whether data ends with the `# EOF` line of OpenMetrics.
+func endsWithEOF(data []byte) bool {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	return bytes.HasSuffix(trimmed, []byte("\n# EOF")) || bytes.Equal(trimmed, []byte("# EOF"))
+}
+
+// withCounterTotals returns mfs with the `_total` suffix added to the names of
+// counter families lacking it. OpenMetrics requires the suffix on counter
+// samples, and an OpenMetrics counter `foo` is exposed as `foo_total` in the
+// Prometheus text format.
+func withCounterTotals(mfs []*dto.MetricFamily) []*dto.MetricFamily {
+	out := make([]*dto.MetricFamily, len(mfs))
+	for i, mf := range mfs {
+		out[i] = mf
+		if mf.GetType() == dto.MetricType_COUNTER && !strings.HasSuffix(mf.GetName(), "_total") {
+			name := mf.GetName() + "_total"
+			out[i] = &dto.MetricFamily{Name: &name, Help: mf.Help, Type: mf.Type, Unit: mf.Unit, Metric: mf.Metric}
+		}
+	}
+	return out
+}
+
+// withOpenMetricsNames returns mfs renamed for the OpenMetrics encoder, which
+// takes `_total` off the name of a counter for its family name. Counters
+// lacking the suffix get it, see withCounterTotals. A counter `foo_total` whose
+// family name would collide with another family `foo` is renamed to
+// `foo_total_total`, so that it is exposed as family `foo_total`.
+func withOpenMetricsNames(mfs []*dto.MetricFamily) []*dto.MetricFamily {
+	out := withCounterTotals(mfs)
+	names := make(map[string]bool, len(mfs))
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	for i, mf := range mfs {
+		family, ok := strings.CutSuffix(mf.GetName(), "_total")
+		if mf.GetType() == dto.MetricType_COUNTER && ok && names[family] {
+			name := mf.GetName() + "_total"
+			out[i] = &dto.MetricFamily{Name: &name, Help: mf.Help, Type: mf.Type, Unit: mf.Unit, Metric: mf.Metric}
+		}
+	}
+	return out
+}
+
+// sortedLabelsKey identifies a label set independent of the order of its
+// pairs.
+func sortedLabelsKey(lps []*dto.LabelPair) string {
+	sorted := append([]*dto.LabelPair(nil), lps...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+	return labelPairsKey(sorted)
+}
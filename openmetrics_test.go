@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+const openMetricsScrape = `# HELP requests Requests.
+# TYPE requests counter
+requests_total{code="200",path="/"} 3
+requests_created{path="/",code="200"} 1700000000.5
+requests_total{code="500",path="/"} 1
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 3.5
+latency_seconds_count 2
+latency_seconds_created 1700000001
+# TYPE up gauge
+up 1
+# EOF
+`
+
+func TestDecodeFamiliesOpenMetrics(t *testing.T) {
+	mfs, err := decodeFamilies([]byte(openMetricsScrape))
+	require.NoError(t, err)
+	require.Len(t, mfs, 3)
+
+	latency, requests := mfs[0], mfs[1]
+	require.Equal(t, "requests", requests.GetName())
+	require.Equal(t, dto.MetricType_COUNTER, requests.GetType())
+	require.Equal(t, "Requests.", requests.GetHelp())
+	require.Len(t, requests.Metric, 2)
+	// _created samples become created timestamps of their label set
+	created := requests.Metric[0].GetCounter().GetCreatedTimestamp()
+	require.Equal(t, int64(1700000000500), created.AsTime().UnixMilli())
+	require.Nil(t, requests.Metric[1].GetCounter().GetCreatedTimestamp())
+
+	require.Equal(t, "latency_seconds", latency.GetName())
+	require.Equal(t, dto.MetricType_HISTOGRAM, latency.GetType())
+	require.Equal(t, int64(1700000001), latency.Metric[0].GetHistogram().GetCreatedTimestamp().GetSeconds())
+
+	// A counter declared with its _total name is not renamed
+	mfs, err = decodeFamilies([]byte("# TYPE requests_total counter\nrequests_total 1\n"))
+	require.NoError(t, err)
+	require.Equal(t, "requests_total", mfs[0].GetName())
+	require.Equal(t, dto.MetricType_COUNTER, mfs[0].GetType())
+}
+
+// pythonClientScrape is the Prometheus text format of the Python client, which
+// exposes created timestamps as gauges of their own.
+const pythonClientScrape = `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="+Inf"} 2.0
+latency_seconds_count 2.0
+latency_seconds_sum 3.5
+# HELP latency_seconds_created Latency.
+# TYPE latency_seconds_created gauge
+latency_seconds_created 1.7e+09
+`
+
+func TestDecodeFamiliesPythonClient(t *testing.T) {
+	require.False(t, isOpenMetrics([]byte(pythonClientScrape)))
+	mfs, err := decodeFamilies([]byte(pythonClientScrape))
+	require.NoError(t, err)
+	require.Len(t, mfs, 2)
+	require.Equal(t, "latency_seconds", mfs[0].GetName())
+	require.Nil(t, mfs[0].Metric[0].GetHistogram().GetCreatedTimestamp())
+	require.Equal(t, "latency_seconds_created", mfs[1].GetName())
+	require.Equal(t, dto.MetricType_GAUGE, mfs[1].GetType())
+
+	// Selecting keeps the Prometheus text format
+	sel, err := ParseSelector(`latency_seconds_created`)
+	require.NoError(t, err)
+	selected, err := SelectScrape([]byte(pythonClientScrape), sel)
+	require.NoError(t, err)
+	require.NotContains(t, string(selected), "# EOF")
+	require.Contains(t, string(selected), "latency_seconds_created 1.7e+09\n")
+}
+
+func TestIsOpenMetrics(t *testing.T) {
+	require.True(t, isOpenMetrics([]byte(openMetricsScrape)))
+	require.True(t, isOpenMetrics([]byte("# TYPE a counter\na_total 1\n")))
+	require.True(t, isOpenMetrics([]byte("up 1\n# EOF")))
+	require.False(t, isOpenMetrics([]byte("# TYPE a_total counter\na_total 1\n")))
+	require.False(t, isOpenMetrics([]byte("# TYPE a counter\na 1\n")))
+}
+
+func TestEncodeFamiliesOpenMetricsCounters(t *testing.T) {
+	mfs, err := decodeFamilies([]byte(openMetricsScrape))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeFamilies(&buf, mfs, exposeFormatOpenMetrics))
+	out := buf.String()
+	require.Contains(t, out, "# TYPE requests counter\n")
+	require.Contains(t, out, `requests_total{code="200",path="/"} 3.0`+"\n")
+	require.Contains(t, out, `requests_created{code="200",path="/"} 1.7000000005e+09`+"\n")
+
+	// Re-encoded OpenMetrics decodes to the same families
+	again, err := decodeFamilies(buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, "requests", again[1].GetName())
+	require.Equal(t, dto.MetricType_COUNTER, again[1].GetType())
+
+	// In the Prometheus text format the counter is named after its samples
+	buf.Reset()
+	require.NoError(t, EncodeFamilies(&buf, withCounterTotals(mfs), exposeFormatProm))
+	require.Contains(t, buf.String(), "# TYPE requests_total counter\nrequests_total{code=\"200\",path=\"/\"} 3\n")
+}
+
+func TestSelectScrapeOpenMetrics(t *testing.T) {
+	sel, err := ParseSelector(`requests{code="200"}`)
+	require.NoError(t, err)
+	selected, err := SelectScrape([]byte(openMetricsScrape), sel)
+	require.NoError(t, err)
+
+	s := SummarizeScrape(selected)
+	require.Len(t, s.Metrics, 1)
+	require.Equal(t, "requests", s.Metrics[0].Name)
+	require.Equal(t, "COUNTER", s.Metrics[0].Type)
+	require.Equal(t, 1, s.Metrics[0].Cardinality)
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 
@@ -14,38 +18,86 @@ import (
 // special key used to count metrics that have no labels
 const noneLabelKey = "<none>"
 
-// parseScrape parses the Prometheus text exposition format from data and returns
-// a sorted slice of MetricSummary containing name, type and description (help)
-// and cardinality (number of metric instances / series).
-// It also returns a map of global label values (label name -> set of values).
-func parseScrape(data []byte) ([]MetricSummary, map[string]map[string]struct{}, error) {
-	// Create a TextParser with explicit validation scheme to avoid relying on
-	// global state. The zero value TextParser is invalid and may panic.
-	parser := expfmt.NewTextParser(prommodel.UTF8Validation)
-	mfs, err := parser.TextToMetricFamilies(bytes.NewReader(data))
-	if err != nil {
-		return nil, nil, err
+// decodeFamilies parses the Prometheus text exposition format (or the
+// delimited protobuf format) from data and returns the metric families sorted
+// by name for deterministic output. OpenMetrics exemplars are ignored, and
+// OpenMetrics counters keep their declared name without `_total`.
+func decodeFamilies(data []byte) ([]*dto.MetricFamily, error) {
+	byName, ok := decodeProtobuf(data)
+	if !ok {
+		// Create a TextParser with explicit validation scheme to avoid relying on
+		// global state. The zero value TextParser is invalid and may panic.
+		parser := expfmt.NewTextParser(prommodel.UTF8Validation)
+		text := normalizeOpenMetrics(stripExemplars(data))
+		var err error
+		byName, err = parser.TextToMetricFamilies(bytes.NewReader(text.data))
+		if err != nil {
+			return nil, err
+		}
+		text.setCreated(byName)
 	}
 
-	// Global map to track distinct values for each label across all metrics
-	globalValues := make(map[string]map[string]struct{})
-
-	// Collect and sort metric names for deterministic output
-	names := make([]string, 0, len(mfs))
-	for name := range mfs {
+	names := make([]string, 0, len(byName))
+	for name := range byName {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
-	metrics := make([]MetricSummary, 0, len(names))
+	mfs := make([]*dto.MetricFamily, 0, len(names))
 	for _, name := range names {
-		mf := mfs[name]
+		mfs = append(mfs, byName[name])
+	}
+	return mfs, nil
+}
+
+// decodeProtobuf decodes data as length-delimited protobuf messages, the
+// format Prometheus negotiates for native histograms. It reports false if data
+// is not in this format.
+func decodeProtobuf(data []byte) (map[string]*dto.MetricFamily, bool) {
+	// Every message starts with its varint length followed by the tag of the
+	// name field; text scrapes start with '#' or a metric name instead.
+	size, n := binary.Uvarint(data)
+	if n <= 0 || n >= len(data) || data[n] != 0x0a || size > uint64(len(data)-n) {
+		return nil, false
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	dec := expfmt.NewDecoder(bytes.NewReader(data), expfmt.NewFormat(expfmt.TypeProtoDelim))
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return byName, true
+			}
+			return nil, false
+		}
+		byName[mf.GetName()] = mf
+	}
+}
+
+// summarizeFamilies turns the metric families decoded from data into a sorted
+// slice of MetricSummary containing name, type and description (help) and
+// cardinality (number of metric instances / series).
+// It also returns a map of global label values (label name -> set of values).
+func summarizeFamilies(mfs []*dto.MetricFamily, data []byte) ([]MetricSummary, map[string]map[string]struct{}) {
+	// Global map to track distinct values for each label across all metrics
+	globalValues := make(map[string]map[string]struct{})
 
+	metrics := make([]MetricSummary, 0, len(mfs))
+	for _, mf := range mfs {
 		// Default cardinality is number of Metric instances in the family
 		card := len(mf.Metric)
 
 		// Collect unique label names appearing in this metric family
 		labelSet := make(map[string]struct{})
+		// Distinct values per label within this metric family
+		familyValues := make(map[string]map[string]struct{})
+		addFamilyValue := func(ln, v string) {
+			if _, ok := familyValues[ln]; !ok {
+				familyValues[ln] = make(map[string]struct{})
+			}
+			familyValues[ln][v] = struct{}{}
+		}
 		for _, m := range mf.Metric {
 			for _, lp := range m.Label {
 				if lp.Name != nil {
@@ -58,6 +110,7 @@ func parseScrape(data []byte) ([]MetricSummary, map[string]map[string]struct{},
 					}
 					if lp.Value != nil {
 						globalValues[ln][*lp.Value] = struct{}{}
+						addFamilyValue(ln, *lp.Value)
 					}
 				}
 			}
@@ -81,6 +134,7 @@ func parseScrape(data []byte) ([]MetricSummary, map[string]map[string]struct{},
 						if b.UpperBound != nil {
 							val := fmt.Sprintf("%g", *b.UpperBound)
 							globalValues["le"][val] = struct{}{}
+							addFamilyValue("le", val)
 						}
 					}
 				}
@@ -103,6 +157,7 @@ func parseScrape(data []byte) ([]MetricSummary, map[string]map[string]struct{},
 						if q.Quantile != nil {
 							val := fmt.Sprintf("%g", *q.Quantile)
 							globalValues["quantile"][val] = struct{}{}
+							addFamilyValue("quantile", val)
 						}
 					}
 				}
@@ -119,13 +174,22 @@ func parseScrape(data []byte) ([]MetricSummary, map[string]map[string]struct{},
 		}
 		sort.Strings(metricLabels)
 
+		var labelValueCounts map[string]int
+		if len(familyValues) > 0 {
+			labelValueCounts = make(map[string]int, len(familyValues))
+			for l, values := range familyValues {
+				labelValueCounts[l] = len(values)
+			}
+		}
+
 		m := MetricSummary{
-			Name:        mf.GetName(),
-			Type:        mf.GetType().String(),
-			Description: mf.GetHelp(),
-			Cardinality: card,
-			Labels:      metricLabels,
-			Size:        0, // filled later by scanning text lines
+			Name:             mf.GetName(),
+			Type:             mf.GetType().String(),
+			Description:      mf.GetHelp(),
+			Cardinality:      card,
+			Labels:           metricLabels,
+			LabelValueCounts: labelValueCounts,
+			Size:             0, // filled later by scanning text lines
 		}
 		metrics = append(metrics, m)
 	}
@@ -161,19 +225,59 @@ func parseScrape(data []byte) ([]MetricSummary, map[string]map[string]struct{},
 		}
 	}
 
-	return metrics, globalValues, nil
+	return metrics, globalValues
 }
 
-// SummarizeScrape composes all available summaries for a scrape.
+// SummarizeScrape composes all available summaries for a scrape. Compressed
+// scrapes must be decompressed first, see decompressScrape.
 func SummarizeScrape(data []byte) ScrapeSummary {
-	metrics, globalValues, err := parseScrape(data)
+	s, _ := summarizeScrapeContext(context.Background(), data)
+	return s
+}
+
+// summarizeScrapeContext is SummarizeScrape, but gives up with the error of
+// ctx once it is done. The expensive steps check it between families.
+func summarizeScrapeContext(ctx context.Context, data []byte) (ScrapeSummary, error) {
+	mfs, err := decodeFamilies(data)
+	var metrics []MetricSummary
+	var globalValues map[string]map[string]struct{}
 	var top []CardinalityEntry
+	var gzipBytes int64
 	if err != nil {
 		// If parsing fails, return size summary and an empty metrics slice.
 		// We avoid exiting here so callers can handle the summary as needed.
 		metrics = []MetricSummary{}
 		globalValues = make(map[string]map[string]struct{})
 	} else {
+		// Protobuf scrapes are measured in their text representation, but
+		// their exemplars are only kept in the decoded families
+		text := data
+		var exemplars map[string]*ExemplarSummary
+		if _, ok := decodeProtobuf(data); ok {
+			exemplars = protobufExemplars(mfs)
+			var buf bytes.Buffer
+			if err := EncodeFamilies(&buf, mfs, exposeFormatProm); err == nil {
+				text = buf.Bytes()
+			}
+		} else {
+			exemplars = textExemplars(data, mfs)
+		}
+		metrics, globalValues = summarizeFamilies(mfs, text)
+		if err := ctx.Err(); err != nil {
+			return ScrapeSummary{}, err
+		}
+
+		var gzipFamilies map[string]familyGzipSize
+		gzipBytes, gzipFamilies, err = gzipSizes(ctx, text, mfs)
+		if err != nil {
+			return ScrapeSummary{}, err
+		}
+		for i := range metrics {
+			metrics[i].Exemplars = exemplars[metrics[i].Name]
+			metrics[i].GzipBytes = gzipFamilies[metrics[i].Name].Alone
+			metrics[i].GzipMarginalBytes = gzipFamilies[metrics[i].Name].Marginal
+		}
+
 		// Compute top 10 metrics by cardinality
 		sort.Slice(metrics, func(i, j int) bool {
 			return metrics[i].Cardinality > metrics[j].Cardinality
@@ -219,14 +323,22 @@ func SummarizeScrape(data []byte) ScrapeSummary {
 		}
 	}
 
-	return ScrapeSummary{
+	summary := ScrapeSummary{
 		Summary: MetricsSummary{
 			Bytes:            SummarizeSize(data).Bytes,
+			GzipBytes:        gzipBytes,
 			TopCardinalities: top,
 			TypesCount:       typesCount,
 			LabelCounts:      labelCounts,
 			LabelValueCounts: labelValueCounts,
 		},
-		Metrics: metrics,
+		Metrics:       metrics,
+		Rollup:        Rollup(metrics, RollupOptions{Depth: defaultRollupDepth}),
+		ValueFindings: AnalyzeValues(mfs),
+		Histograms:    AnalyzeHistograms(mfs, HistogramOptions{}),
+		Summaries:     AnalyzeSummaries(mfs, defaultSummaryBuckets),
 	}
+	summary.setTopLabelValues(mfs, defaultTopLabelValues)
+	summary.Summary.LabelLengths, summary.Summary.LongestLabelValues = AnalyzeLabelLengths(mfs)
+	return summary, nil
 }
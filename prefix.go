@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// PrefixNode is a node of the metric name prefix tree. Names are split on
+// "_", so `prometheus_tsdb_compactions_total` is a family leaf below the
+// prefixes `prometheus`, `prometheus_tsdb` and `prometheus_tsdb_compactions`.
+// Prefix nodes aggregate the series, bytes and family count of everything
+// below them.
+type PrefixNode struct {
+	// Name is the full prefix or, for family leaves, the family name.
+	Name     string        `json:"name"`
+	Family   bool          `json:"family,omitempty"`
+	Series   int           `json:"series"`
+	Bytes    int64         `json:"bytes"`
+	Families int           `json:"families"`
+	Children []*PrefixNode `json:"children,omitempty"`
+}
+
+// buildPrefixTree arranges metrics in a prefix tree below an unnamed root.
+// Children are sorted by series (descending), then name.
+func buildPrefixTree(metrics []MetricSummary) *PrefixNode {
+	root := &PrefixNode{}
+	index := map[string]*PrefixNode{"": root}
+
+	for _, m := range metrics {
+		parent := root
+		parts := strings.Split(m.Name, "_")
+		for i := 1; i < len(parts); i++ {
+			prefix := strings.Join(parts[:i], "_")
+			node, ok := index[prefix]
+			if !ok {
+				node = &PrefixNode{Name: prefix}
+				index[prefix] = node
+				parent.Children = append(parent.Children, node)
+			}
+			parent = node
+		}
+		parent.Children = append(parent.Children, &PrefixNode{
+			Name:     m.Name,
+			Family:   true,
+			Series:   m.Cardinality,
+			Bytes:    m.Size,
+			Families: 1,
+		})
+	}
+
+	aggregatePrefixTree(root)
+	return root
+}
+
+// aggregatePrefixTree sums up the values of the children of every prefix node
+// and sorts the children.
+func aggregatePrefixTree(n *PrefixNode) {
+	if n.Family {
+		return
+	}
+	n.Series, n.Bytes, n.Families = 0, 0, 0
+	for _, c := range n.Children {
+		aggregatePrefixTree(c)
+		n.Series += c.Series
+		n.Bytes += c.Bytes
+		n.Families += c.Families
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].Series == n.Children[j].Series {
+			return n.Children[i].Name < n.Children[j].Name
+		}
+		return n.Children[i].Series > n.Children[j].Series
+	})
+}
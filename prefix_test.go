@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPrefixTree(t *testing.T) {
+	root := buildPrefixTree([]MetricSummary{
+		{Name: "go_goroutines", Cardinality: 1, Size: 10},
+		{Name: "go_gc_duration_seconds", Cardinality: 5, Size: 50},
+		{Name: "go_gc_cycles_total", Cardinality: 1, Size: 20},
+		{Name: "up", Cardinality: 2, Size: 8},
+	})
+
+	require.Equal(t, 9, root.Series)
+	require.Equal(t, int64(88), root.Bytes)
+	require.Equal(t, 4, root.Families)
+
+	// Children are sorted by series, families are leaves below their prefixes
+	require.Len(t, root.Children, 2)
+	goNode := root.Children[0]
+	require.Equal(t, "go", goNode.Name)
+	require.False(t, goNode.Family)
+	require.Equal(t, 7, goNode.Series)
+	require.Equal(t, 3, goNode.Families)
+	require.Equal(t, &PrefixNode{Name: "up", Family: true, Series: 2, Bytes: 8, Families: 1}, root.Children[1])
+
+	gc := goNode.Children[0]
+	require.Equal(t, "go_gc", gc.Name)
+	require.Equal(t, 6, gc.Series)
+	require.Equal(t, int64(70), gc.Bytes)
+	require.Equal(t, "go_gc_duration", gc.Children[0].Name)
+	require.Equal(t, "go_gc_duration_seconds", gc.Children[0].Children[0].Name)
+	require.True(t, gc.Children[0].Children[0].Family)
+	require.Equal(t, "go_goroutines", goNode.Children[1].Name)
+	require.True(t, goNode.Children[1].Family)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+	yaml "go.yaml.in/yaml/v2"
+)
+
+// Defaults of a Prometheus scrape config.
+const (
+	defaultScrapeScheme   = "http"
+	defaultMetricsPath    = "/metrics"
+	defaultScrapeInterval = time.Minute
+	defaultScrapeTimeout  = 10 * time.Second
+)
+
+// PromConfig is the subset of a Prometheus configuration file needed to find
+// and scrape targets. Unknown fields are ignored.
+type PromConfig struct {
+	Global        PromGlobalConfig   `yaml:"global"`
+	ScrapeConfigs []PromScrapeConfig `yaml:"scrape_configs"`
+
+	// dir is the directory of the configuration file; relative file paths
+	// are resolved against it.
+	dir string
+}
+
+// PromGlobalConfig holds the global defaults of scrape configs.
+type PromGlobalConfig struct {
+	ScrapeInterval string `yaml:"scrape_interval"`
+	ScrapeTimeout  string `yaml:"scrape_timeout"`
+}
+
+// PromScrapeConfig is a `scrape_configs` entry.
+type PromScrapeConfig struct {
+	JobName        string              `yaml:"job_name"`
+	ScrapeInterval string              `yaml:"scrape_interval"`
+	ScrapeTimeout  string              `yaml:"scrape_timeout"`
+	MetricsPath    string              `yaml:"metrics_path"`
+	Scheme         string              `yaml:"scheme"`
+	Params         map[string][]string `yaml:"params"`
+
+	PromHTTPConfig `yaml:",inline"`
+
+	StaticConfigs  []PromTargetGroup    `yaml:"static_configs"`
+	FileSDConfigs  []PromFileSDConfig   `yaml:"file_sd_configs"`
+	RelabelConfigs []*PromRelabelConfig `yaml:"relabel_configs"`
+}
+
+// PromTargetGroup is a static config or a file_sd target group.
+type PromTargetGroup struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+// PromFileSDConfig lists JSON or YAML files (or globs) with target groups.
+type PromFileSDConfig struct {
+	Files []string `yaml:"files"`
+}
+
+// LoadPromConfig reads a Prometheus configuration file.
+func LoadPromConfig(path string) (*PromConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c PromConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	c.dir = filepath.Dir(path)
+
+	seen := make(map[string]bool)
+	for i, sc := range c.ScrapeConfigs {
+		if sc.JobName == "" {
+			return nil, fmt.Errorf("scrape config %d: missing job_name", i+1)
+		}
+		if seen[sc.JobName] {
+			return nil, fmt.Errorf("duplicate job_name %q", sc.JobName)
+		}
+		seen[sc.JobName] = true
+
+		sc := &c.ScrapeConfigs[i]
+		if err := sc.PromHTTPConfig.validate(); err != nil {
+			return nil, fmt.Errorf("job %q: %w", sc.JobName, err)
+		}
+		sc.PromHTTPConfig.resolvePaths(c.resolvePath)
+		for _, rc := range sc.RelabelConfigs {
+			if err := rc.compile(); err != nil {
+				return nil, fmt.Errorf("job %q: %w", sc.JobName, err)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// resolvePath makes a path from the configuration file absolute.
+func (c *PromConfig) resolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.dir, path)
+}
+
+// scrapeTimeout returns the effective timeout of a scrape config.
+func (c *PromConfig) scrapeTimeout(sc PromScrapeConfig) (time.Duration, error) {
+	return firstDuration(defaultScrapeTimeout, sc.ScrapeTimeout, c.Global.ScrapeTimeout)
+}
+
+// scrapeInterval returns the effective interval of a scrape config.
+func (c *PromConfig) scrapeInterval(sc PromScrapeConfig) (time.Duration, error) {
+	return firstDuration(defaultScrapeInterval, sc.ScrapeInterval, c.Global.ScrapeInterval)
+}
+
+// firstDuration parses the first non-empty Prometheus duration (e.g. "15s",
+// "1m") or returns def.
+func firstDuration(def time.Duration, values ...string) (time.Duration, error) {
+	for _, v := range values {
+		if v != "" {
+			d, err := prommodel.ParseDuration(v)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q: %w", v, err)
+			}
+			return time.Duration(d), nil
+		}
+	}
+	return def, nil
+}
+
+// ScrapeTarget is a target after relabeling: the URL to scrape and its
+// final (non-internal) labels.
+type ScrapeTarget struct {
+	Job      string            `json:"job"`
+	Instance string            `json:"instance"`
+	URL      string            `json:"url"`
+	Labels   map[string]string `json:"labels"`
+
+	config  *PromScrapeConfig
+	timeout time.Duration
+}
+
+// Targets discovers the targets of all scrape configs from static and file
+// based service discovery and applies relabeling. Dropped targets are
+// omitted. Targets are sorted by job, then instance.
+func (c *PromConfig) Targets() ([]ScrapeTarget, error) {
+	var targets []ScrapeTarget
+	for i := range c.ScrapeConfigs {
+		sc := &c.ScrapeConfigs[i]
+		timeout, err := c.scrapeTimeout(*sc)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", sc.JobName, err)
+		}
+		interval, err := c.scrapeInterval(*sc)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", sc.JobName, err)
+		}
+
+		groups, err := c.targetGroups(*sc)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", sc.JobName, err)
+		}
+		for _, g := range groups {
+			for _, addr := range g.Targets {
+				labels := map[string]string{
+					prommodel.AddressLabel:        addr,
+					prommodel.SchemeLabel:         orDefault(sc.Scheme, defaultScrapeScheme),
+					prommodel.MetricsPathLabel:    orDefault(sc.MetricsPath, defaultMetricsPath),
+					prommodel.ScrapeIntervalLabel: prommodel.Duration(interval).String(),
+					prommodel.ScrapeTimeoutLabel:  prommodel.Duration(timeout).String(),
+					prommodel.JobLabel:            sc.JobName,
+				}
+				for name, values := range sc.Params {
+					if len(values) > 0 {
+						labels[prommodel.ParamLabelPrefix+name] = values[0]
+					}
+				}
+				for k, v := range g.Labels {
+					labels[k] = v
+				}
+
+				if !relabel(labels, sc.RelabelConfigs) {
+					continue
+				}
+				t, ok := newScrapeTarget(labels, sc)
+				if !ok {
+					continue
+				}
+				t.timeout = timeout
+				targets = append(targets, t)
+			}
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].Job == targets[j].Job {
+			return targets[i].Instance < targets[j].Instance
+		}
+		return targets[i].Job < targets[j].Job
+	})
+	return targets, nil
+}
+
+// targetGroups returns the static target groups followed by those read from
+// file_sd_configs, whose targets carry the __meta_filepath label.
+func (c *PromConfig) targetGroups(sc PromScrapeConfig) ([]PromTargetGroup, error) {
+	groups := append([]PromTargetGroup(nil), sc.StaticConfigs...)
+	for _, sd := range sc.FileSDConfigs {
+		for _, pattern := range sd.Files {
+			files, err := filepath.Glob(c.resolvePath(pattern))
+			if err != nil {
+				return nil, fmt.Errorf("invalid file_sd pattern %q: %w", pattern, err)
+			}
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return nil, err
+				}
+				// JSON is valid YAML, so both file_sd formats parse the same way
+				var fileGroups []PromTargetGroup
+				if err := yaml.Unmarshal(data, &fileGroups); err != nil {
+					return nil, fmt.Errorf("parsing %s: %w", file, err)
+				}
+				for _, g := range fileGroups {
+					labels := map[string]string{"__meta_filepath": file}
+					for k, v := range g.Labels {
+						labels[k] = v
+					}
+					g.Labels = labels
+					groups = append(groups, g)
+				}
+			}
+		}
+	}
+	return groups, nil
+}
+
+// newScrapeTarget builds the scrape URL from the relabeled internal labels
+// and keeps the public ones, like Prometheus does. It reports false if the
+// target has no address left.
+func newScrapeTarget(labels map[string]string, sc *PromScrapeConfig) (ScrapeTarget, bool) {
+	addr := labels[prommodel.AddressLabel]
+	if addr == "" {
+		return ScrapeTarget{}, false
+	}
+
+	params := url.Values{}
+	for name, values := range sc.Params {
+		params[name] = append([]string(nil), values...)
+	}
+	for k, v := range labels {
+		if name, ok := strings.CutPrefix(k, prommodel.ParamLabelPrefix); ok {
+			if len(params[name]) == 0 {
+				params[name] = []string{v}
+			} else {
+				params[name][0] = v
+			}
+		}
+	}
+	u := url.URL{
+		Scheme:   labels[prommodel.SchemeLabel],
+		Host:     addr,
+		Path:     labels[prommodel.MetricsPathLabel],
+		RawQuery: params.Encode(),
+	}
+
+	public := make(map[string]string)
+	for k, v := range labels {
+		if !strings.HasPrefix(k, prommodel.ReservedLabelPrefix) {
+			public[k] = v
+		}
+	}
+	if _, ok := public[prommodel.InstanceLabel]; !ok {
+		public[prommodel.InstanceLabel] = addr
+	}
+	return ScrapeTarget{
+		Job:      public[prommodel.JobLabel],
+		Instance: public[prommodel.InstanceLabel],
+		URL:      u.String(),
+		Labels:   public,
+		config:   sc,
+	}, true
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tokenAlphabet contains the characters used for redacted label values. None
+// of them needs escaping in the exposition format.
+const tokenAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// maxTokenAttempts bounds the retries when a token collides with the token of
+// another value of the same label, before a longer token is used.
+const maxTokenAttempts = 1000
+
+// alwaysKeptLabels are never redacted because their values are structural:
+// the parser requires them to be numbers.
+var alwaysKeptLabels = map[string]struct{}{
+	"le":       {},
+	"quantile": {},
+}
+
+// RedactOptions selects the labels whose values are replaced. If Labels is
+// non-empty only those labels are redacted (allowlist), otherwise every label
+// not in Keep is redacted (denylist).
+type RedactOptions struct {
+	Labels []string
+	Keep   []string
+	// Salt keys the token hash. The same salt yields the same tokens across
+	// runs.
+	Salt []byte
+}
+
+// Redactor replaces label values in a scrape with pseudonymous tokens. Each
+// distinct value of a label is mapped to a distinct token of the same byte
+// length, escaped as well as unescaped, so series counts, label value counts,
+// value lengths and byte sizes are unchanged.
+// Only if a label has more distinct values of a length than there are tokens
+// of that length (e.g. more than 36 single-byte values), the remaining values
+// get longer tokens.
+type Redactor struct {
+	opts   RedactOptions
+	labels map[string]struct{}
+	keep   map[string]struct{}
+
+	// per label: escaped value -> token, and the set of tokens handed out
+	tokens map[string]map[string]string
+	used   map[string]map[string]struct{}
+}
+
+// NewRedactor creates a Redactor for the given options.
+func NewRedactor(opts RedactOptions) *Redactor {
+	r := &Redactor{
+		opts:   opts,
+		labels: make(map[string]struct{}, len(opts.Labels)),
+		keep:   make(map[string]struct{}, len(opts.Keep)),
+		tokens: make(map[string]map[string]string),
+		used:   make(map[string]map[string]struct{}),
+	}
+	for _, l := range opts.Labels {
+		r.labels[l] = struct{}{}
+	}
+	for _, l := range opts.Keep {
+		r.keep[l] = struct{}{}
+	}
+	return r
+}
+
+// shouldRedact reports whether values of the label are replaced.
+func (r *Redactor) shouldRedact(label string) bool {
+	if _, ok := alwaysKeptLabels[label]; ok {
+		return false
+	}
+	if len(r.labels) > 0 {
+		_, ok := r.labels[label]
+		return ok
+	}
+	_, ok := r.keep[label]
+	return !ok
+}
+
+// Redact rewrites the label values of every sample line (and exemplar) in
+// data. Comments, metric names, values and timestamps are copied unchanged.
+func (r *Redactor) Redact(data []byte) ([]byte, error) {
+	lines := strings.Split(string(data), "\n")
+	var b strings.Builder
+	b.Grow(len(data))
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			b.WriteString(line)
+			continue
+		}
+
+		s, err := parseSampleLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		b.WriteString(r.redactLine(line, append(s.Labels, s.ExemplarLabels...)))
+	}
+	return []byte(b.String()), nil
+}
+
+// redactLine replaces the escaped values of the redacted labels within line.
+// The labels must be ordered by their position in the line.
+func (r *Redactor) redactLine(line string, labels []rawLabel) string {
+	var b strings.Builder
+	last := 0
+	for _, l := range labels {
+		if !r.shouldRedact(l.Name) {
+			continue
+		}
+		token := r.token(l.Name, line[l.ValueStart:l.ValueEnd])
+		b.WriteString(line[last:l.ValueStart])
+		b.WriteString(token)
+		last = l.ValueEnd
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// token returns the token for the escaped value raw of label, generating a
+// new one if the value has not been seen before. New tokens have the length
+// of the value both escaped and unescaped, unless all tokens of that length
+// are taken: every escape sequence of the value becomes an escaped backslash
+// at the end of the token.
+func (r *Redactor) token(label, raw string) string {
+	if t, ok := r.tokens[label][raw]; ok {
+		return t
+	}
+	if _, ok := r.tokens[label]; !ok {
+		r.tokens[label] = make(map[string]string)
+		r.used[label] = make(map[string]struct{})
+	}
+
+	escapes := 0
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			escapes++
+			i++
+		}
+	}
+	suffix := strings.Repeat(`\\`, escapes)
+	for n := len(raw) - len(suffix); ; n++ {
+		for attempt := 0; attempt < maxTokenAttempts; attempt++ {
+			t := r.hashToken(label, raw, attempt, n) + suffix
+			if _, taken := r.used[label][t]; taken {
+				continue
+			}
+			r.tokens[label][raw] = t
+			r.used[label][t] = struct{}{}
+			return t
+		}
+	}
+}
+
+// hashToken derives a token of length n from the keyed hash of the label,
+// the value and the attempt counter.
+func (r *Redactor) hashToken(label, raw string, attempt, n int) string {
+	token := make([]byte, 0, n)
+	for block := uint32(0); len(token) < n; block++ {
+		mac := hmac.New(sha256.New, r.opts.Salt)
+		mac.Write([]byte(label))
+		mac.Write([]byte{0})
+		mac.Write([]byte(raw))
+		var ctr [8]byte
+		binary.BigEndian.PutUint32(ctr[:4], uint32(attempt))
+		binary.BigEndian.PutUint32(ctr[4:], block)
+		mac.Write(ctr[:])
+		for _, c := range mac.Sum(nil) {
+			if len(token) == n {
+				break
+			}
+			token = append(token, tokenAlphabet[int(c)%len(tokenAlphabet)])
+		}
+	}
+	return string(token)
+}
+
+// runRedact implements the `redact` command: it reads a scrape from in and
+// writes the redacted scrape to out.
+func runRedact(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("redact", flag.ContinueOnError)
+	labels := fs.String("labels", "", "Comma-separated allowlist: only redact these labels")
+	keep := fs.String("keep", "", "Comma-separated denylist: redact all labels except these")
+	salt := fs.String("salt", "", "Secret used to derive tokens; reuse it to get the same tokens across runs (default: random)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *labels != "" && *keep != "" {
+		return fmt.Errorf("--labels and --keep are mutually exclusive")
+	}
+
+	opts := RedactOptions{
+		Labels: splitList(*labels),
+		Keep:   splitList(*keep),
+		Salt:   []byte(*salt),
+	}
+	if *salt == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return fmt.Errorf("generating salt: %w", err)
+		}
+		opts.Salt = []byte(hex.EncodeToString(random))
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("reading input: %w", err)
+	}
+	redacted, err := NewRedactor(opts).Redact(data)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(redacted)
+	return err
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
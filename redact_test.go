@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor_PreservesStructure(t *testing.T) {
+	data, err := os.ReadFile("test-resources/prometheus-scrape.txt")
+	require.NoError(t, err)
+
+	redacted, err := NewRedactor(RedactOptions{Keep: []string{"code"}, Salt: []byte("s3cret")}).Redact(data)
+	require.NoError(t, err)
+
+	require.Equal(t, len(data), len(redacted), "redaction must not change the size")
+	require.NotContains(t, string(redacted), `handler="/api/v1/query"`)
+	require.Contains(t, string(redacted), `code="200"`)
+
+	// Everything but the values themselves and the gzip sizes, which depend
+	// on them, is unchanged
+	original := SummarizeScrape(data)
+	summary := SummarizeScrape(redacted)
+	require.Equal(t, original.Summary.Bytes, summary.Summary.Bytes)
+	require.Equal(t, original.Summary.TopCardinalities, summary.Summary.TopCardinalities)
+	require.Equal(t, original.Summary.TypesCount, summary.Summary.TypesCount)
+	require.Equal(t, original.Summary.LabelCounts, summary.Summary.LabelCounts)
+	require.Equal(t, original.Summary.LabelValueCounts, summary.Summary.LabelValueCounts)
+	require.Equal(t, original.Summary.LabelLengths, summary.Summary.LabelLengths)
+	requireSameTopCounts(t, original.Summary.LabelTopValues, summary.Summary.LabelTopValues)
+	require.Equal(t, original.Summary.LabelTopValues["code"], summary.Summary.LabelTopValues["code"])
+	require.Len(t, summary.Summary.LongestLabelValues, len(original.Summary.LongestLabelValues))
+	for i, long := range original.Summary.LongestLabelValues {
+		require.Equal(t, long.Length, summary.Summary.LongestLabelValues[i].Length)
+	}
+
+	require.Len(t, summary.Metrics, len(original.Metrics))
+	for i, m := range original.Metrics {
+		r := summary.Metrics[i]
+		require.Equal(t, m.Name, r.Name)
+		require.Equal(t, m.Type, r.Type)
+		require.Equal(t, m.Cardinality, r.Cardinality, m.Name)
+		require.Equal(t, m.Labels, r.Labels, m.Name)
+		require.Equal(t, m.LabelValueCounts, r.LabelValueCounts, m.Name)
+		require.Equal(t, m.Size, r.Size, m.Name)
+		require.Equal(t, m.Exemplars, r.Exemplars, m.Name)
+		requireSameTopCounts(t, m.LabelTopValues, r.LabelTopValues)
+	}
+
+	// No redacted value shows up in the top or longest values
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+	secrets := make(map[string]bool)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			for _, lp := range m.Label {
+				if lp.GetName() != "code" {
+					secrets[lp.GetValue()] = true
+				}
+			}
+		}
+	}
+	require.NotEmpty(t, secrets)
+	tops := []map[string]LabelValues{summary.Summary.LabelTopValues}
+	for _, m := range summary.Metrics {
+		tops = append(tops, m.LabelTopValues)
+	}
+	for _, top := range tops {
+		for label, lv := range top {
+			for _, v := range lv.Top {
+				if label != "code" && label != "le" && label != "quantile" {
+					require.False(t, secrets[v.Value], "%s=%q", label, v.Value)
+				}
+			}
+		}
+	}
+	for _, long := range summary.Summary.LongestLabelValues {
+		for secret := range secrets {
+			require.NotContains(t, long.Series, `"`+secret+`"`)
+		}
+	}
+}
+
+// requireSameTopCounts asserts that two sets of top label values have the
+// same labels and series counts, whatever their values.
+func requireSameTopCounts(t *testing.T, expected, actual map[string]LabelValues) {
+	t.Helper()
+	require.Len(t, actual, len(expected))
+	for label, lv := range expected {
+		got, ok := actual[label]
+		require.True(t, ok, label)
+		require.Equal(t, lv.OtherValues, got.OtherValues, label)
+		require.Equal(t, lv.OtherSeries, got.OtherSeries, label)
+		require.Len(t, got.Top, len(lv.Top), label)
+		for i, v := range lv.Top {
+			require.Equal(t, v.Series, got.Top[i].Series, label)
+		}
+	}
+}
+
+func TestRedactor_Tokens(t *testing.T) {
+	input := `# HELP req Requests by "customer".
+req{customer="acme",host="a\"b"} 1
+req{customer="acme",host="web-2"} 2
+req{customer="initech",host="web-2"} 3 # {trace_id="4bf92f35"} 1
+lat_bucket{customer="acme",le="0.5"} 1
+`
+	redact := func(opts RedactOptions) string {
+		out, err := NewRedactor(opts).Redact([]byte(input))
+		require.NoError(t, err)
+		require.Equal(t, len(input), len(out))
+		return string(out)
+	}
+
+	out := redact(RedactOptions{Salt: []byte("x")})
+	lines := strings.Split(out, "\n")
+	require.Equal(t, `# HELP req Requests by "customer".`, lines[0])
+
+	first, err := parseSampleLine(lines[1])
+	require.NoError(t, err)
+	second, err := parseSampleLine(lines[2])
+	require.NoError(t, err)
+	third, err := parseSampleLine(lines[3])
+	require.NoError(t, err)
+	bucket, err := parseSampleLine(lines[4])
+	require.NoError(t, err)
+
+	// Equal values get equal tokens, distinct values distinct tokens
+	require.Equal(t, first.Labels[0].Value, second.Labels[0].Value)
+	require.NotEqual(t, first.Labels[0].Value, third.Labels[0].Value)
+	require.NotEqual(t, "acme", first.Labels[0].Value)
+	require.Len(t, first.Labels[1].Value, len(`a"b`), "unescaped lengths are kept")
+	require.Equal(t, second.Labels[1].Value, third.Labels[1].Value)
+	require.NotEqual(t, "4bf92f35", third.ExemplarLabels[0].Value)
+	require.Equal(t, "0.5", bucket.Labels[1].Value, "le is never redacted")
+	require.Equal(t, "3", third.Value)
+
+	// The same salt produces the same tokens, a different salt different ones
+	require.Equal(t, out, redact(RedactOptions{Salt: []byte("x")}))
+	require.NotEqual(t, out, redact(RedactOptions{Salt: []byte("y")}))
+
+	// Allowlist only touches the listed labels
+	allow := redact(RedactOptions{Labels: []string{"host"}, Salt: []byte("x")})
+	require.Contains(t, allow, `customer="initech"`)
+	require.Contains(t, allow, `trace_id="4bf92f35"`)
+	require.NotContains(t, allow, `host="web-2"`)
+}
+
+func TestRedactor_EscapedValues(t *testing.T) {
+	input := `path{p="C:\\temp",q="say \"hi\""} 1
+path{p="a\nb",q="\\"} 1
+path{p="x",q="\\\\"} 1
+`
+	out, err := NewRedactor(RedactOptions{Salt: []byte("x")}).Redact([]byte(input))
+	require.NoError(t, err)
+	require.Len(t, out, len(input))
+	require.NotContains(t, string(out), "temp")
+
+	// Unescaped lengths are kept too
+	original, err := decodeFamilies([]byte(input))
+	require.NoError(t, err)
+	redacted, err := decodeFamilies(out)
+	require.NoError(t, err)
+	lengths, longest := AnalyzeLabelLengths(original)
+	redactedLengths, redactedLongest := AnalyzeLabelLengths(redacted)
+	require.Equal(t, lengths, redactedLengths)
+	require.Len(t, redactedLongest, len(longest))
+	for i := range longest {
+		require.Equal(t, longest[i].Length, redactedLongest[i].Length)
+	}
+	require.Len(t, redacted[0].Metric, 3, "distinct values keep distinct tokens")
+}
+
+func TestRedactor_ManyShortValues(t *testing.T) {
+	// 62 distinct single-byte values, but only 36 single-byte tokens
+	const values = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	var b strings.Builder
+	for _, v := range values {
+		fmt.Fprintf(&b, "up{id=%q} 1\n", string(v))
+	}
+	out, err := NewRedactor(RedactOptions{Salt: []byte("x")}).Redact([]byte(b.String()))
+	require.NoError(t, err)
+
+	tokens := make(map[string]struct{})
+	short := 0
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		s, err := parseSampleLine(line)
+		require.NoError(t, err)
+		tokens[s.Labels[0].Value] = struct{}{}
+		if len(s.Labels[0].Value) == 1 {
+			short++
+		}
+	}
+	require.Len(t, tokens, len(values), "every value gets a distinct token")
+	require.Equal(t, len(tokenAlphabet), short, "all single-byte tokens are used before longer ones")
+}
+
+func TestRunRedact(t *testing.T) {
+	var out bytes.Buffer
+	err := runRedact([]string{"--labels", "a", "--keep", "b"}, strings.NewReader(""), &out)
+	require.Error(t, err)
+
+	err = runRedact([]string{"--salt", "x"}, strings.NewReader("m{a=\"secret\"} 1\n"), &out)
+	require.NoError(t, err)
+	require.NotContains(t, out.String(), "secret")
+	require.Len(t, out.String(), len("m{a=\"secret\"} 1\n"))
+}
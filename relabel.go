@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Relabel actions supported by relabel.
+const (
+	relabelReplace   = "replace"
+	relabelKeep      = "keep"
+	relabelDrop      = "drop"
+	relabelKeepEqual = "keepequal"
+	relabelDropEqual = "dropequal"
+	relabelHashMod   = "hashmod"
+	relabelLabelMap  = "labelmap"
+	relabelLabelDrop = "labeldrop"
+	relabelLabelKeep = "labelkeep"
+	relabelLowercase = "lowercase"
+	relabelUppercase = "uppercase"
+)
+
+// PromRelabelConfig is a `relabel_configs` entry. Unset fields take the
+// Prometheus defaults once compiled.
+type PromRelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    *string  `yaml:"separator"`
+	Regex        *string  `yaml:"regex"`
+	Modulus      uint64   `yaml:"modulus"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  *string  `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+
+	regexp *regexp.Regexp
+}
+
+// compile applies the defaults and compiles the (fully anchored) regex.
+func (rc *PromRelabelConfig) compile() error {
+	if rc.Action == "" {
+		rc.Action = relabelReplace
+	}
+	rc.Action = strings.ToLower(rc.Action)
+	if rc.Separator == nil {
+		sep := ";"
+		rc.Separator = &sep
+	}
+	if rc.Replacement == nil {
+		repl := "$1"
+		rc.Replacement = &repl
+	}
+	expr := "(.*)"
+	if rc.Regex != nil {
+		expr = *rc.Regex
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid relabel regex %q: %w", expr, err)
+	}
+	rc.regexp = re
+
+	switch rc.Action {
+	case relabelReplace, relabelHashMod, relabelLowercase, relabelUppercase, relabelKeepEqual, relabelDropEqual:
+		if rc.TargetLabel == "" {
+			return fmt.Errorf("relabel action %q requires target_label", rc.Action)
+		}
+	case relabelKeep, relabelDrop, relabelLabelMap, relabelLabelDrop, relabelLabelKeep:
+	default:
+		return fmt.Errorf("unknown relabel action %q", rc.Action)
+	}
+	if rc.Action == relabelHashMod && rc.Modulus == 0 {
+		return fmt.Errorf("relabel action hashmod requires modulus")
+	}
+	return nil
+}
+
+// relabel applies the relabel configs to labels in place, with the semantics
+// of Prometheus. It reports false if the target was dropped.
+func relabel(labels map[string]string, configs []*PromRelabelConfig) bool {
+	for _, rc := range configs {
+		values := make([]string, len(rc.SourceLabels))
+		for i, l := range rc.SourceLabels {
+			values[i] = labels[l]
+		}
+		val := strings.Join(values, *rc.Separator)
+
+		switch rc.Action {
+		case relabelDrop:
+			if rc.regexp.MatchString(val) {
+				return false
+			}
+		case relabelKeep:
+			if !rc.regexp.MatchString(val) {
+				return false
+			}
+		case relabelDropEqual:
+			if labels[rc.TargetLabel] == val {
+				return false
+			}
+		case relabelKeepEqual:
+			if labels[rc.TargetLabel] != val {
+				return false
+			}
+		case relabelReplace:
+			indexes := rc.regexp.FindStringSubmatchIndex(val)
+			if indexes == nil {
+				break
+			}
+			target := string(rc.regexp.ExpandString(nil, rc.TargetLabel, val, indexes))
+			if target == "" {
+				break
+			}
+			res := string(rc.regexp.ExpandString(nil, *rc.Replacement, val, indexes))
+			if res == "" {
+				delete(labels, target)
+				break
+			}
+			labels[target] = res
+		case relabelLowercase:
+			labels[rc.TargetLabel] = strings.ToLower(val)
+		case relabelUppercase:
+			labels[rc.TargetLabel] = strings.ToUpper(val)
+		case relabelHashMod:
+			// The last 8 bytes of the MD5 sum, as in Prometheus
+			sum := md5.Sum([]byte(val))
+			labels[rc.TargetLabel] = fmt.Sprintf("%d", binary.BigEndian.Uint64(sum[8:])%rc.Modulus)
+		case relabelLabelMap:
+			for _, name := range sortedKeys(labels) {
+				if rc.regexp.MatchString(name) {
+					labels[rc.regexp.ReplaceAllString(name, *rc.Replacement)] = labels[name]
+				}
+			}
+		case relabelLabelDrop, relabelLabelKeep:
+			for _, name := range sortedKeys(labels) {
+				if rc.regexp.MatchString(name) == (rc.Action == relabelLabelDrop) {
+					delete(labels, name)
+				}
+			}
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	yaml "go.yaml.in/yaml/v2"
+)
+
+func compileRelabelConfigs(t *testing.T, src string) []*PromRelabelConfig {
+	t.Helper()
+	var configs []*PromRelabelConfig
+	require.NoError(t, yaml.Unmarshal([]byte(src), &configs))
+	for _, rc := range configs {
+		require.NoError(t, rc.compile())
+	}
+	return configs
+}
+
+func TestRelabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs string
+		labels  map[string]string
+		want    map[string]string
+	}{
+		{
+			name: "replace with capture groups",
+			configs: `
+- source_labels: [__address__]
+  regex: '([^:]+):\d+'
+  target_label: host
+`,
+			labels: map[string]string{"__address__": "node1:9100"},
+			want:   map[string]string{"__address__": "node1:9100", "host": "node1"},
+		},
+		{
+			name: "replace without match keeps labels",
+			configs: `
+- source_labels: [__address__]
+  regex: 'nomatch'
+  target_label: host
+`,
+			labels: map[string]string{"__address__": "node1:9100"},
+			want:   map[string]string{"__address__": "node1:9100"},
+		},
+		{
+			name: "replace to empty deletes the label",
+			configs: `
+- source_labels: [missing]
+  target_label: env
+`,
+			labels: map[string]string{"env": "prod"},
+			want:   map[string]string{},
+		},
+		{
+			name: "source labels are joined with the separator",
+			configs: `
+- source_labels: [a, b]
+  separator: '-'
+  target_label: c
+`,
+			labels: map[string]string{"a": "x", "b": "y"},
+			want:   map[string]string{"a": "x", "b": "y", "c": "x-y"},
+		},
+		{
+			name: "labelmap",
+			configs: `
+- action: labelmap
+  regex: '__meta_(.+)'
+`,
+			labels: map[string]string{"__meta_zone": "eu", "job": "node"},
+			want:   map[string]string{"__meta_zone": "eu", "zone": "eu", "job": "node"},
+		},
+		{
+			name: "labeldrop and labelkeep",
+			configs: `
+- action: labeldrop
+  regex: 'tmp_.*'
+- action: labelkeep
+  regex: 'job|env'
+`,
+			labels: map[string]string{"tmp_a": "1", "job": "node", "env": "prod", "other": "x"},
+			want:   map[string]string{"job": "node", "env": "prod"},
+		},
+		{
+			name: "lowercase and uppercase",
+			configs: `
+- action: lowercase
+  source_labels: [env]
+  target_label: env_lower
+- action: uppercase
+  source_labels: [env]
+  target_label: env_upper
+`,
+			labels: map[string]string{"env": "Prod"},
+			want:   map[string]string{"env": "Prod", "env_lower": "prod", "env_upper": "PROD"},
+		},
+		{
+			name: "hashmod",
+			configs: `
+- action: hashmod
+  source_labels: [__address__]
+  modulus: 4
+  target_label: shard
+`,
+			labels: map[string]string{"__address__": "node1:9100"},
+			want:   map[string]string{"__address__": "node1:9100", "shard": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.True(t, relabel(tt.labels, compileRelabelConfigs(t, tt.configs)))
+			require.Equal(t, tt.want, tt.labels)
+		})
+	}
+}
+
+func TestRelabelDropsTargets(t *testing.T) {
+	configs := compileRelabelConfigs(t, `
+- action: keep
+  source_labels: [env]
+  regex: prod|staging
+- action: drop
+  source_labels: [__address__]
+  regex: '.*:9999'
+- action: dropequal
+  source_labels: [__address__]
+  target_label: skip
+`)
+	require.True(t, relabel(map[string]string{"env": "prod", "__address__": "a:9100"}, configs))
+	require.False(t, relabel(map[string]string{"env": "dev", "__address__": "a:9100"}, configs))
+	require.False(t, relabel(map[string]string{"env": "prod", "__address__": "a:9999"}, configs))
+	require.False(t, relabel(map[string]string{"env": "prod", "__address__": "a:9100", "skip": "a:9100"}, configs))
+
+	keepEqual := compileRelabelConfigs(t, `
+- action: keepequal
+  source_labels: [a]
+  target_label: b
+`)
+	require.True(t, relabel(map[string]string{"a": "x", "b": "x"}, keepEqual))
+	require.False(t, relabel(map[string]string{"a": "x", "b": "y"}, keepEqual))
+}
+
+func TestRelabelConfigCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		"action: unknown",
+		"regex: '('",
+		"action: hashmod\ntarget_label: shard",
+		"action: replace",
+	} {
+		var rc PromRelabelConfig
+		require.NoError(t, yaml.Unmarshal([]byte(src), &rc))
+		require.Error(t, rc.compile(), src)
+	}
+}
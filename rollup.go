@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultRollupDepth is the number of name segments SummarizeScrape groups by.
+const defaultRollupDepth = 1
+
+// RollupGroup aggregates the metric families sharing a name prefix (or
+// matching a custom group). Shares are fractions of all series and bytes.
+type RollupGroup struct {
+	Name        string        `json:"name"`
+	Families    int           `json:"families"`
+	Series      int           `json:"series"`
+	Bytes       int64         `json:"bytes"`
+	SeriesShare float64       `json:"series_share"`
+	BytesShare  float64       `json:"bytes_share"`
+	Children    []RollupGroup `json:"children,omitempty"`
+}
+
+// RollupRule assigns families whose name matches Regexp to the group Name.
+type RollupRule struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// ParseRollupRule parses a custom group given as `name=regex`. The regular
+// expression is unanchored, like a grep over metric names.
+func ParseRollupRule(s string) (RollupRule, error) {
+	name, expr, ok := strings.Cut(s, "=")
+	if !ok || name == "" || expr == "" {
+		return RollupRule{}, fmt.Errorf("invalid rollup group %q (expected name=regex)", s)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return RollupRule{}, fmt.Errorf("invalid rollup group %q: %w", s, err)
+	}
+	return RollupRule{Name: name, Regexp: re}, nil
+}
+
+// RollupOptions configures Rollup.
+type RollupOptions struct {
+	// Depth is the number of levels to report. The first level groups by the
+	// first "_"-separated name segment, each deeper level by one more segment.
+	Depth int
+	// Rules define custom top-level groups. A family is assigned to the first
+	// matching rule; families matching none are grouped by prefix.
+	Rules []RollupRule
+}
+
+// Rollup groups metric families hierarchically by name prefix and reports
+// series, bytes and family count per group. Groups are sorted by series
+// (descending), then name. The groups are read off the prefix tree the html
+// and svg outputs show.
+func Rollup(metrics []MetricSummary, opts RollupOptions) []RollupGroup {
+	depth := max(opts.Depth, 1)
+
+	var totalSeries int
+	var totalBytes int64
+	for _, m := range metrics {
+		totalSeries += m.Cardinality
+		totalBytes += m.Size
+	}
+	r := rollup{totalSeries: totalSeries, totalBytes: totalBytes}
+
+	// Custom groups first; their children are prefix groups from level one.
+	var groups []RollupGroup
+	rest := metrics
+	if len(opts.Rules) > 0 {
+		byRule := make([][]MetricSummary, len(opts.Rules))
+		rest = nil
+	metrics:
+		for _, m := range metrics {
+			for i, rule := range opts.Rules {
+				if rule.Regexp.MatchString(m.Name) {
+					byRule[i] = append(byRule[i], m)
+					continue metrics
+				}
+			}
+			rest = append(rest, m)
+		}
+		for i, rule := range opts.Rules {
+			if len(byRule[i]) == 0 {
+				continue
+			}
+			tree := buildPrefixTree(byRule[i])
+			g := r.group(rule.Name, tree)
+			if depth > 1 {
+				g.Children = r.level(tree.Children, depth-1)
+			}
+			groups = append(groups, g)
+		}
+	}
+
+	if len(rest) > 0 {
+		groups = append(groups, r.level(buildPrefixTree(rest).Children, depth)...)
+	}
+	sortRollupGroups(groups)
+	return groups
+}
+
+type rollup struct {
+	totalSeries int
+	totalBytes  int64
+}
+
+// level turns the nodes of one level of the prefix tree into groups and
+// recurses for the remaining levels. A family and a prefix of the same name,
+// e.g. `foo` and the prefix of `foo_bar`, form one group; below, the family is
+// its own group next to the children of the prefix.
+func (r rollup) level(nodes []*PrefixNode, remaining int) []RollupGroup {
+	byName := make(map[string][]*PrefixNode)
+	for _, n := range nodes {
+		byName[n.Name] = append(byName[n.Name], n)
+	}
+
+	groups := make([]RollupGroup, 0, len(byName))
+	for name, members := range byName {
+		g := r.group(name, members...)
+		if remaining > 1 {
+			var next []*PrefixNode
+			for _, n := range members {
+				if n.Family {
+					next = append(next, n)
+				} else {
+					next = append(next, n.Children...)
+				}
+			}
+			children := r.level(next, remaining-1)
+			// A single child with the group's own name adds no information
+			if len(children) > 1 || (len(children) == 1 && children[0].Name != name) {
+				g.Children = children
+			}
+		}
+		groups = append(groups, g)
+	}
+	sortRollupGroups(groups)
+	return groups
+}
+
+// group sums up prefix tree nodes as the group name.
+func (r rollup) group(name string, nodes ...*PrefixNode) RollupGroup {
+	g := RollupGroup{Name: name}
+	for _, n := range nodes {
+		g.Families += n.Families
+		g.Series += n.Series
+		g.Bytes += n.Bytes
+	}
+	if r.totalSeries > 0 {
+		g.SeriesShare = float64(g.Series) / float64(r.totalSeries)
+	}
+	if r.totalBytes > 0 {
+		g.BytesShare = float64(g.Bytes) / float64(r.totalBytes)
+	}
+	return g
+}
+
+func sortRollupGroups(groups []RollupGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Series == groups[j].Series {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].Series > groups[j].Series
+	})
+}
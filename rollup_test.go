@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollup(t *testing.T) {
+	metrics := []MetricSummary{
+		{Name: "go_gc_duration_seconds", Cardinality: 5, Size: 500},
+		{Name: "go_goroutines", Cardinality: 1, Size: 100},
+		{Name: "process_cpu_seconds_total", Cardinality: 1, Size: 100},
+		{Name: "http_requests_total", Cardinality: 3, Size: 300},
+	}
+
+	groups := Rollup(metrics, RollupOptions{Depth: 1})
+	require.Equal(t, []RollupGroup{
+		{Name: "go", Families: 2, Series: 6, Bytes: 600, SeriesShare: 0.6, BytesShare: 0.6},
+		{Name: "http", Families: 1, Series: 3, Bytes: 300, SeriesShare: 0.3, BytesShare: 0.3},
+		{Name: "process", Families: 1, Series: 1, Bytes: 100, SeriesShare: 0.1, BytesShare: 0.1},
+	}, groups)
+
+	// Drill down: deeper levels become children, but a lone child with the
+	// group's own name is omitted.
+	groups = Rollup(metrics, RollupOptions{Depth: 3})
+	require.Equal(t, "go", groups[0].Name)
+	require.Len(t, groups[0].Children, 2)
+	require.Equal(t, "go_gc", groups[0].Children[0].Name)
+	require.Equal(t, "go_gc_duration", groups[0].Children[0].Children[0].Name)
+	require.Equal(t, "go_goroutines", groups[0].Children[1].Name)
+	require.Nil(t, groups[0].Children[1].Children)
+
+	// Custom regex groups come before prefix grouping
+	runtime, err := ParseRollupRule(`runtime=^(go|process)_`)
+	require.NoError(t, err)
+	groups = Rollup(metrics, RollupOptions{Depth: 2, Rules: []RollupRule{runtime}})
+	require.Len(t, groups, 2)
+	require.Equal(t, "runtime", groups[0].Name)
+	require.Equal(t, 3, groups[0].Families)
+	require.Equal(t, 7, groups[0].Series)
+	require.Equal(t, []string{"go", "process"}, []string{groups[0].Children[0].Name, groups[0].Children[1].Name})
+	require.Equal(t, "http", groups[1].Name)
+
+	for _, bad := range []string{"runtime", "=go_", "x=(", "x="} {
+		_, err := ParseRollupRule(bad)
+		require.Error(t, err, "expected %q to be rejected", bad)
+	}
+}
+
+func TestRollupMatchesPrefixTree(t *testing.T) {
+	metrics := []MetricSummary{
+		{Name: "foo", Cardinality: 1, Size: 10},
+		{Name: "foo_bar", Cardinality: 2, Size: 20},
+		{Name: "foo_bar_baz", Cardinality: 4, Size: 40},
+		{Name: "up", Cardinality: 1, Size: 5},
+	}
+
+	// The family foo and the prefix foo form one group
+	groups := Rollup(metrics, RollupOptions{Depth: 3})
+	require.Len(t, groups, 2)
+	foo := groups[0]
+	require.Equal(t, RollupGroup{Name: "foo", Families: 3, Series: 7, Bytes: 70, SeriesShare: 7.0 / 8, BytesShare: 70.0 / 75}, RollupGroup{
+		Name: foo.Name, Families: foo.Families, Series: foo.Series, Bytes: foo.Bytes, SeriesShare: foo.SeriesShare, BytesShare: foo.BytesShare,
+	})
+	require.Equal(t, []string{"foo_bar", "foo"}, []string{foo.Children[0].Name, foo.Children[1].Name})
+	require.Equal(t, 6, foo.Children[0].Series)
+	require.Equal(t, []string{"foo_bar_baz", "foo_bar"}, []string{foo.Children[0].Children[0].Name, foo.Children[0].Children[1].Name})
+	require.Nil(t, groups[1].Children)
+
+	// Every top-level group adds up the prefix tree nodes of its name
+	tree := buildPrefixTree(metrics)
+	for _, g := range groups {
+		var series int
+		var bytes int64
+		for _, n := range tree.Children {
+			if n.Name == g.Name {
+				series += n.Series
+				bytes += n.Bytes
+			}
+		}
+		require.Equal(t, series, g.Series, g.Name)
+		require.Equal(t, bytes, g.Bytes, g.Name)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the version of the JSON output format. It is incremented
+// whenever fields are removed, renamed or change their type; added fields
+// don't change it.
+const schemaVersion = 1
+
+// schemaID identifies the published JSON Schema of the output, which is kept
+// in the repository at schema/summary.v1.json.
+const schemaID = "https://raw.githubusercontent.com/FRosner/scrapecli/main/schema/summary.v1.json"
+
+// SummaryOutput is the JSON output of a scrape summary: the ScrapeSummary
+// fields plus the schema version and metadata about the run.
+type SummaryOutput struct {
+	SchemaVersion int        `json:"schema_version"`
+	Meta          OutputMeta `json:"meta"`
+	ScrapeSummary
+}
+
+// OutputMeta describes where and when an output was produced.
+type OutputMeta struct {
+	// Source is the input the summary was produced from, e.g. "stdin".
+	Source      string    `json:"source"`
+	GeneratedAt time.Time `json:"generated_at"`
+	ToolVersion string    `json:"tool_version"`
+}
+
+// newSummaryOutput wraps a summary of source for JSON output.
+func newSummaryOutput(s ScrapeSummary, source string, now time.Time) SummaryOutput {
+	return SummaryOutput{
+		SchemaVersion: schemaVersion,
+		Meta:          OutputMeta{Source: source, GeneratedAt: now.UTC(), ToolVersion: toolVersion()},
+		ScrapeSummary: s,
+	}
+}
+
+// JSONSchema generates a JSON Schema (draft 2020-12) of the JSON encoding of
+// v's type. Named struct types are defined once under $defs. Fields without
+// omitempty are required; slices and maps may be null.
+func JSONSchema(v any, id, title string) map[string]any {
+	g := schemaGenerator{defs: make(map[string]any)}
+	root := g.schema(reflect.TypeOf(v))
+	schema := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     id,
+		"title":   title,
+		"$defs":   g.defs,
+	}
+	for k, v := range root {
+		schema[k] = v
+	}
+	return schema
+}
+
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return nullable(g.schema(t.Elem()))
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return nullable(map[string]any{"type": "array", "items": g.schema(t.Elem())})
+	case reflect.Map:
+		return nullable(map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())})
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			// Reserve the name first, the type may refer to itself
+			g.defs[t.Name()] = nil
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	default:
+		panic(fmt.Sprintf("no JSON schema for %s", t))
+	}
+}
+
+// nullable allows null in addition to the given schema.
+func nullable(s map[string]any) map[string]any {
+	if ref, ok := s["$ref"]; ok {
+		return map[string]any{"anyOf": []any{map[string]any{"$ref": ref}, map[string]any{"type": "null"}}}
+	}
+	if typ, ok := s["type"].(string); ok {
+		s["type"] = []string{typ, "null"}
+	}
+	return s
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	g.addFields(t, properties, &required)
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// addFields adds the JSON fields of struct t, following encoding/json: embedded
+// structs without a name tag are inlined, unexported fields are skipped.
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.addFields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// summarySchema returns the JSON Schema of SummaryOutput, pinned to the
+// current schema version.
+func summarySchema() map[string]any {
+	schema := JSONSchema(SummaryOutput{}, schemaID, "scrapecli scrape summary")
+	output := schema["$defs"].(map[string]any)["SummaryOutput"].(map[string]any)
+	output["properties"].(map[string]any)["schema_version"] = map[string]any{"type": "integer", "const": schemaVersion}
+	return schema
+}
+
+// runSchema implements `scrapecli schema`, which prints the JSON Schema of
+// the JSON output.
+func runSchema(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: scrapecli schema")
+	}
+	b, err := json.MarshalIndent(summarySchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(b))
+	return err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "Update golden files")
+
+// requireGolden compares got to the golden file at path, or updates the file
+// when the tests are run with -update.
+func requireGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got), "output differs from %s; if the change is intended, run go test -update and bump schemaVersion for incompatible changes", path)
+}
+
+func goldenSummaryOutput(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("test-resources/golden/scrape.txt")
+	require.NoError(t, err)
+	s := SummarizeScrape(data)
+	s.Rollup = Rollup(s.Metrics, RollupOptions{Depth: defaultRollupDepth})
+	out := newSummaryOutput(s, "stdin", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	out.Meta.ToolVersion = "test"
+	b, err := json.MarshalIndent(out, "", "  ")
+	require.NoError(t, err)
+	return append(b, '\n')
+}
+
+func TestSchemaGolden(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runSchema(nil, &out))
+	requireGolden(t, "schema/summary.v1.json", out.Bytes())
+}
+
+func TestSummaryOutputGolden(t *testing.T) {
+	requireGolden(t, "test-resources/golden/summary.json", goldenSummaryOutput(t))
+}
+
+func TestSummaryOutputMatchesSchema(t *testing.T) {
+	var schema, doc any
+	b, err := json.Marshal(summarySchema())
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &schema))
+	require.NoError(t, json.Unmarshal(goldenSummaryOutput(t), &doc))
+
+	defs := schema.(map[string]any)["$defs"].(map[string]any)
+	var errs []string
+	validateJSON(defs, schema.(map[string]any), doc, "$", &errs)
+	require.Empty(t, errs)
+
+	// Fields missing from the schema are detected
+	doc.(map[string]any)["unexpected"] = 1
+	validateJSON(defs, schema.(map[string]any), doc, "$", &errs)
+	require.Equal(t, []string{"$: unknown property unexpected"}, errs)
+}
+
+// validateJSON checks doc against the subset of JSON Schema produced by
+// JSONSchema and collects the violations in errs.
+func validateJSON(defs map[string]any, schema map[string]any, doc any, path string, errs *[]string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		validateJSON(defs, defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any), doc, path, errs)
+		return
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, alt := range anyOf {
+			var altErrs []string
+			validateJSON(defs, alt.(map[string]any), doc, path, &altErrs)
+			if len(altErrs) == 0 {
+				return
+			}
+		}
+		*errs = append(*errs, path+": matches no alternative")
+		return
+	}
+	if c, ok := schema["const"]; ok && c != doc {
+		*errs = append(*errs, path+": unexpected value")
+	}
+
+	var types []string
+	switch typ := schema["type"].(type) {
+	case string:
+		types = []string{typ}
+	case []any:
+		for _, t := range typ {
+			types = append(types, t.(string))
+		}
+	}
+	docType := "null"
+	switch v := doc.(type) {
+	case bool:
+		docType = "boolean"
+	case float64:
+		docType = "number"
+		if v == float64(int64(v)) {
+			docType = "integer"
+		}
+	case string:
+		docType = "string"
+	case []any:
+		docType = "array"
+	case map[string]any:
+		docType = "object"
+	}
+	matches := false
+	for _, typ := range types {
+		matches = matches || typ == docType || (typ == "number" && docType == "integer")
+	}
+	if !matches {
+		*errs = append(*errs, path+": is "+docType+", expected "+strings.Join(types, " or "))
+		return
+	}
+
+	switch v := doc.(type) {
+	case []any:
+		for i, item := range v {
+			validateJSON(defs, schema["items"].(map[string]any), item, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case map[string]any:
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			for _, name := range schema["required"].([]any) {
+				if _, ok := v[name.(string)]; !ok {
+					*errs = append(*errs, path+": missing "+name.(string))
+				}
+			}
+			for name, value := range v {
+				prop, ok := properties[name]
+				if !ok {
+					*errs = append(*errs, path+": unknown property "+name)
+					continue
+				}
+				validateJSON(defs, prop.(map[string]any), value, path+"."+name, errs)
+			}
+		} else if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+			for name, value := range v {
+				validateJSON(defs, additional, value, path+"."+name, errs)
+			}
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	type node struct {
+		Name     string         `json:"name"`
+		Children []node         `json:"children,omitempty"`
+		Parent   *node          `json:"parent"`
+		Tags     map[string]int `json:"tags,omitempty"`
+		Hidden   string         `json:"-"`
+		internal string
+	}
+	schema := JSONSchema(node{}, "id", "title")
+	require.Equal(t, "#/$defs/node", schema["$ref"])
+	def := schema["$defs"].(map[string]any)["node"].(map[string]any)
+	require.Equal(t, []string{"name", "parent"}, def["required"])
+
+	props := def["properties"].(map[string]any)
+	require.Len(t, props, 4)
+	require.Equal(t, map[string]any{"type": []string{"array", "null"}, "items": map[string]any{"$ref": "#/$defs/node"}}, props["children"])
+	require.Equal(t, map[string]any{"anyOf": []any{map[string]any{"$ref": "#/$defs/node"}, map[string]any{"type": "null"}}}, props["parent"])
+	require.Equal(t, map[string]any{"type": []string{"object", "null"}, "additionalProperties": map[string]any{"type": "integer"}}, props["tags"])
+}
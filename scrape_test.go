@@ -90,6 +90,8 @@ func TestSummarizeScrape_Integration(t *testing.T) {
 	// go_gc_heap_allocs_by_size_bytes is a histogram, so it must have "le" label.
 	// It is fetched into 'b' above.
 	require.Contains(t, b.Labels, "le", "go_gc_heap_allocs_by_size_bytes should have 'le' label")
+	require.Equal(t, map[string]int{"le": 12}, b.LabelValueCounts, "go_gc_heap_allocs_by_size_bytes should have 12 distinct 'le' values")
+	require.Nil(t, g.LabelValueCounts, "go_goroutines has no labels")
 
 	// Verify LabelCounts consistency
 	labelCountsFromMetrics := make(map[string]int)
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	dto "github.com/prometheus/client_model/go"
+	prommodel "github.com/prometheus/common/model"
+)
+
+// MatchType is the comparison operator of a LabelMatcher.
+type MatchType int
+
+const (
+	MatchEqual     MatchType = iota // =
+	MatchNotEqual                   // !=
+	MatchRegexp                     // =~
+	MatchNotRegexp                  // !~
+)
+
+func (t MatchType) String() string {
+	switch t {
+	case MatchEqual:
+		return "="
+	case MatchNotEqual:
+		return "!="
+	case MatchRegexp:
+		return "=~"
+	case MatchNotRegexp:
+		return "!~"
+	}
+	return "?"
+}
+
+// LabelMatcher matches a single label value, like a matcher in a PromQL
+// vector selector. Regular expressions are fully anchored.
+type LabelMatcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+
+	re *regexp.Regexp
+}
+
+// NewLabelMatcher builds a LabelMatcher, compiling the regular expression for
+// the regexp match types.
+func NewLabelMatcher(t MatchType, name, value string) (*LabelMatcher, error) {
+	m := &LabelMatcher{Name: name, Type: t, Value: value}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression for label %q: %w", name, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches reports whether the label value v satisfies the matcher. As in
+// PromQL, a missing label is treated as having the empty value.
+func (m *LabelMatcher) Matches(v string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+func (m *LabelMatcher) String() string {
+	return m.Name + m.Type.String() + strconv.Quote(m.Value)
+}
+
+// Selector is a list of label matchers that must all match for a series to
+// be selected.
+type Selector []*LabelMatcher
+
+// Matches reports whether a series with the given labels (including
+// __name__) satisfies every matcher of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, m := range s {
+		if !m.Matches(labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSampleNames returns an error if the positive __name__ matchers of s
+// match one of the sample names of a family but not the family name. Series
+// are selected by family name, so e.g. `{__name__=~".*_bucket"}` would
+// silently select nothing, while PromQL selects the bucket samples.
+func (s Selector) checkSampleNames(family string, samples ...string) error {
+	if s.matchesName(family) {
+		return nil
+	}
+	for _, sample := range samples {
+		if sample != family && s.matchesName(sample) {
+			return fmt.Errorf("selector %s matches sample %q but not its family %q: __name__ matches family names, so histograms, summaries and counters are selected as a whole", s, sample, family)
+		}
+	}
+	return nil
+}
+
+// matchesName reports whether the positive (= and =~) __name__ matchers of s
+// match name.
+func (s Selector) matchesName(name string) bool {
+	for _, m := range s {
+		if m.Name == prommodel.MetricNameLabel && (m.Type == MatchEqual || m.Type == MatchRegexp) && !m.Matches(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// sampleNames returns the names of the samples a family is exposed as.
+func sampleNames(mf *dto.MetricFamily) []string {
+	name := mf.GetName()
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		// Counters of OpenMetrics scrapes are named without _total
+		if !strings.HasSuffix(name, "_total") {
+			return []string{name + "_total", name + "_created"}
+		}
+	case dto.MetricType_HISTOGRAM:
+		return []string{name + "_bucket", name + "_sum", name + "_count", name + "_created"}
+	case dto.MetricType_SUMMARY:
+		return []string{name, name + "_sum", name + "_count", name + "_created"}
+	}
+	return []string{name}
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, m := range s {
+		parts[i] = m.String()
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// ParseSelector parses a PromQL vector selector such as
+// `http_requests_total{code=~"5..", handler!="/metrics"}`. The metric name may
+// be given in front of the braces, as a quoted string inside them, or through
+// a __name__ matcher. Offsets, ranges and modifiers are not supported.
+func ParseSelector(input string) (Selector, error) {
+	p := &selectorParser{in: input}
+	sel, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", input, err)
+	}
+	return sel, nil
+}
+
+type selectorParser struct {
+	in  string
+	pos int
+}
+
+func (p *selectorParser) parse() (Selector, error) {
+	var sel Selector
+
+	p.skipSpace()
+	if name := p.identifier(true); name != "" {
+		m, _ := NewLabelMatcher(MatchEqual, prommodel.MetricNameLabel, name)
+		sel = append(sel, m)
+	}
+
+	p.skipSpace()
+	if p.peek() == '{' {
+		p.pos++
+		for {
+			p.skipSpace()
+			if p.peek() == '}' {
+				p.pos++
+				break
+			}
+			m, err := p.matcher()
+			if err != nil {
+				return nil, err
+			}
+			// Buckets and quantiles can't be selected on their own
+			if m.Name == prommodel.BucketLabel || m.Name == prommodel.QuantileLabel {
+				return nil, fmt.Errorf("matchers on %s are not supported: histograms and summaries are selected per label set as a whole", m.Name)
+			}
+			sel = append(sel, m)
+
+			p.skipSpace()
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case '}':
+			default:
+				return nil, p.errorf("expected ',' or '}'")
+			}
+		}
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.in) {
+		return nil, p.errorf("unexpected trailing input")
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("selector must contain at least one matcher")
+	}
+	return sel, nil
+}
+
+// matcher parses `name op "value"` or a bare quoted metric name.
+func (p *selectorParser) matcher() (*LabelMatcher, error) {
+	var name string
+	if isQuote(p.peek()) {
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if c := p.peek(); c == ',' || c == '}' {
+			// A lone quoted string inside braces is the metric name.
+			return NewLabelMatcher(MatchEqual, prommodel.MetricNameLabel, s)
+		}
+		name = s
+	} else {
+		name = p.identifier(false)
+		if name == "" {
+			return nil, p.errorf("expected label name")
+		}
+	}
+
+	p.skipSpace()
+	var t MatchType
+	switch {
+	case strings.HasPrefix(p.in[p.pos:], "=~"):
+		t, p.pos = MatchRegexp, p.pos+2
+	case strings.HasPrefix(p.in[p.pos:], "!~"):
+		t, p.pos = MatchNotRegexp, p.pos+2
+	case strings.HasPrefix(p.in[p.pos:], "!="):
+		t, p.pos = MatchNotEqual, p.pos+2
+	case strings.HasPrefix(p.in[p.pos:], "="):
+		t, p.pos = MatchEqual, p.pos+1
+	default:
+		return nil, p.errorf("expected one of =, !=, =~, !~ after label %q", name)
+	}
+
+	p.skipSpace()
+	if !isQuote(p.peek()) {
+		return nil, p.errorf("expected quoted value for label %q", name)
+	}
+	value, err := p.quoted()
+	if err != nil {
+		return nil, err
+	}
+	return NewLabelMatcher(t, name, value)
+}
+
+// identifier consumes a legacy metric name (colons allowed) or label name.
+func (p *selectorParser) identifier(metricName bool) string {
+	start := p.pos
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		ok := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(metricName && c == ':') || (p.pos > start && c >= '0' && c <= '9')
+		if !ok {
+			break
+		}
+		p.pos++
+	}
+	return p.in[start:p.pos]
+}
+
+// quoted consumes a PromQL string literal in double quotes, single quotes or
+// backticks and returns its unescaped value.
+func (p *selectorParser) quoted() (string, error) {
+	q := p.in[p.pos]
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		if c == '\\' && q != '`' {
+			p.pos += 2
+			continue
+		}
+		p.pos++
+		if c == q {
+			lit := p.in[start:p.pos]
+			s, err := unquoteLiteral(lit)
+			if err != nil {
+				return "", p.errorf("invalid string literal %s", lit)
+			}
+			return s, nil
+		}
+	}
+	return "", p.errorf("unterminated string literal")
+}
+
+// unquoteLiteral unquotes a double, single or back quoted string literal.
+// Single quoted literals are unescaped like double quoted ones, except that
+// both \' and \" stand for the quote character.
+func unquoteLiteral(lit string) (string, error) {
+	if lit[0] != '\'' {
+		return strconv.Unquote(lit)
+	}
+	s := lit[1 : len(lit)-1]
+	var b strings.Builder
+	for len(s) > 0 {
+		if strings.HasPrefix(s, `\"`) {
+			b.WriteByte('"')
+			s = s[2:]
+			continue
+		}
+		c, multibyte, tail, err := strconv.UnquoteChar(s, '\'')
+		if err != nil {
+			return "", err
+		}
+		if c < utf8.RuneSelf || !multibyte {
+			b.WriteByte(byte(c))
+		} else {
+			b.WriteRune(c)
+		}
+		s = tail
+	}
+	return b.String(), nil
+}
+
+func (p *selectorParser) skipSpace() {
+	for p.pos < len(p.in) && strings.ContainsRune(" \t\n\r", rune(p.in[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *selectorParser) peek() byte {
+	if p.pos >= len(p.in) {
+		return 0
+	}
+	return p.in[p.pos]
+}
+
+func (p *selectorParser) errorf(format string, args ...any) error {
+	col := utf8.RuneCountInString(p.in[:min(p.pos, len(p.in))]) + 1
+	return fmt.Errorf("column %d: %s", col, fmt.Sprintf(format, args...))
+}
+
+func isQuote(c byte) bool {
+	return c == '"' || c == '\'' || c == '`'
+}
+
+// filterFamilies returns the metric families reduced to the series matched by
+// sel. The __name__ label of a series is its family name, so histograms and
+// summaries are kept or dropped as a whole; selectors that would match their
+// samples differently are rejected. Families left without series are dropped.
+func filterFamilies(mfs []*dto.MetricFamily, sel Selector) ([]*dto.MetricFamily, error) {
+	families := make(map[string]bool, len(mfs))
+	for _, mf := range mfs {
+		families[mf.GetName()] = true
+	}
+	out := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		// A sample name can be a family of its own, e.g. the _created gauges
+		// of the Python client
+		var samples []string
+		for _, sample := range sampleNames(mf) {
+			if !families[sample] {
+				samples = append(samples, sample)
+			}
+		}
+		if err := sel.checkSampleNames(mf.GetName(), samples...); err != nil {
+			return nil, err
+		}
+		var kept []*dto.Metric
+		for _, m := range mf.Metric {
+			labels := make(map[string]string, len(m.Label)+1)
+			for _, lp := range m.Label {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			labels[prommodel.MetricNameLabel] = mf.GetName()
+			if sel.Matches(labels) {
+				kept = append(kept, m)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		filtered := &dto.MetricFamily{
+			Name:   mf.Name,
+			Help:   mf.Help,
+			Type:   mf.Type,
+			Unit:   mf.Unit,
+			Metric: kept,
+		}
+		out = append(out, filtered)
+	}
+	return out, nil
+}
+
+// SelectScrape parses data, keeps only the series matched by sel and returns
+// the remaining families re-encoded in the text exposition format (OpenMetrics
+// for OpenMetrics input), ready to be passed to SummarizeScrape.
+func SelectScrape(data []byte, sel Selector) ([]byte, error) {
+	mfs, err := decodeFamilies(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenMetrics counters are re-encoded with their sample names
+	format := exposeFormatProm
+	if isOpenMetrics(data) {
+		format = exposeFormatOpenMetrics
+	}
+	selected, err := filterFamilies(mfs, sel)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := EncodeFamilies(&buf, selected, format); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelector(t *testing.T) {
+	sel, err := ParseSelector(`http_requests_total{code=~"5..", handler!='/metrics', "method"!~` + "`GET|HEAD`" + `,}`)
+	require.NoError(t, err)
+	require.Equal(t, `{__name__="http_requests_total", code=~"5..", handler!="/metrics", method!~"GET|HEAD"}`, sel.String())
+
+	sel, err = ParseSelector(`{"my.metric", env="prod"}`)
+	require.NoError(t, err)
+	require.Equal(t, `{__name__="my.metric", env="prod"}`, sel.String())
+
+	// Regular expressions are anchored and a missing label has the empty value.
+	sel, err = ParseSelector(`{__name__=~"http_.*", handler=""}`)
+	require.NoError(t, err)
+	require.True(t, sel.Matches(map[string]string{"__name__": "http_requests_total"}))
+	require.False(t, sel.Matches(map[string]string{"__name__": "x_http_requests_total"}))
+	require.False(t, sel.Matches(map[string]string{"__name__": "http_requests_total", "handler": "/"}))
+
+	// Single quoted strings may escape either quote
+	for expr, value := range map[string]string{
+		`{a='x\"y'}`:  `x"y`,
+		`{a='\''}`:    `'`,
+		`{a='"\n\\'}`: "\"\n\\",
+		`{a='é\x41'}`: "éA",
+	} {
+		sel, err := ParseSelector(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, value, sel[0].Value, expr)
+	}
+
+	for _, bad := range []string{``, `{}`, `{a='\q'}`, `foo{`, `{a="b"`, `{a=b}`, `{a~"b"}`, `{a=~"("}`, `{a="b"} offset 5m`, `{a="b}`, `{le="0.5"}`, `foo{quantile!="0.99"}`} {
+		_, err := ParseSelector(bad)
+		require.Error(t, err, "expected %q to be rejected", bad)
+	}
+}
+
+func TestSelectScrape(t *testing.T) {
+	data, err := os.ReadFile("test-resources/prometheus-scrape.txt")
+	require.NoError(t, err)
+
+	sel, err := ParseSelector(`{__name__=~"prometheus_http_.*", handler!~"/api/v1/.*"}`)
+	require.NoError(t, err)
+
+	selected, err := SelectScrape(data, sel)
+	require.NoError(t, err)
+
+	full := SummarizeScrape(data)
+	summary := SummarizeScrape(selected)
+
+	require.Equal(t, int64(len(selected)), summary.Summary.Bytes)
+	require.Less(t, summary.Summary.Bytes, full.Summary.Bytes)
+	require.NotEmpty(t, summary.Metrics)
+
+	for _, m := range summary.Metrics {
+		require.Regexp(t, `^prometheus_http_`, m.Name)
+	}
+	for _, e := range summary.Summary.TopCardinalities {
+		require.Regexp(t, `^prometheus_http_`, e.Name)
+	}
+
+	// Histograms are kept or dropped per label set, so fewer handler values remain.
+	require.Less(t, summary.Summary.LabelValueCounts["handler"], full.Summary.LabelValueCounts["handler"])
+	require.Greater(t, summary.Summary.LabelValueCounts["handler"], 0)
+}
+
+func TestSelectScrapeSampleNames(t *testing.T) {
+	data := []byte(`# TYPE latency_seconds histogram
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 3.5
+latency_seconds_count 2
+# TYPE requests counter
+requests_total 3
+# TYPE up gauge
+up 1
+`)
+	// Sample names of histograms and OpenMetrics counters are rejected
+	for _, expr := range []string{`{__name__=~".*_bucket"}`, `latency_seconds_count`, `requests_total`} {
+		sel, err := ParseSelector(expr)
+		require.NoError(t, err)
+		_, err = SelectScrape(data, sel)
+		require.ErrorContains(t, err, "but not its family", expr)
+	}
+
+	// Family names and negative matchers select whole families
+	for expr, families := range map[string]int{
+		`latency_seconds`:               1,
+		`{__name__=~"latency_.*|up"}`:   2,
+		`{__name__!="latency_seconds"}`: 2,
+		`{__name__!~".*_bucket"}`:       3,
+	} {
+		sel, err := ParseSelector(expr)
+		require.NoError(t, err)
+		selected, err := SelectScrape(data, sel)
+		require.NoError(t, err, expr)
+		require.Len(t, SummarizeScrape(selected).Metrics, families, expr)
+	}
+}
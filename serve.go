@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// Defaults of the serve command.
+const (
+	defaultServeListen      = ":9799"
+	defaultServeInterval    = time.Minute
+	defaultServeMaxFamilies = 100
+	defaultServeMaxLabels   = 50
+	// otherFamily aggregates the families beyond the exported maximum.
+	otherFamily = "__other__"
+)
+
+// ExporterOptions bound the output of the exporter: only the families with
+// the most series and the labels with the most distinct values are exported
+// per target.
+type ExporterOptions struct {
+	MaxFamilies int
+	MaxLabels   int
+}
+
+// exporter scrapes targets periodically and exposes their summaries as
+// metrics.
+type exporter struct {
+	targets []ScrapeTarget
+	headers http.Header
+	workers int
+	opts    ExporterOptions
+
+	mu      sync.RWMutex
+	results []TargetSummary
+}
+
+func newExporter(targets []ScrapeTarget, headers http.Header, workers int, opts ExporterOptions) *exporter {
+	return &exporter{targets: targets, headers: headers, workers: workers, opts: opts}
+}
+
+// scrape scrapes all targets once and replaces the exported results.
+func (e *exporter) scrape(ctx context.Context) {
+	results := scrapeTargets(ctx, e.targets, e.headers, e.workers)
+	e.mu.Lock()
+	e.results = results
+	e.mu.Unlock()
+}
+
+// run scrapes the targets every interval until ctx is done.
+func (e *exporter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.scrape(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP exposes the latest results in the Prometheus text format.
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	if err := EncodeFamilies(w, e.families(), exposeFormatProm); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// families returns the metric families describing the latest results. Every
+// target is identified by its URL in the target label.
+func (e *exporter) families() []*dto.MetricFamily {
+	e.mu.RLock()
+	results := e.results
+	e.mu.RUnlock()
+
+	up := newGaugeFamily("scrapecli_scrape_success", "Whether the last scrape of the target succeeded.")
+	duration := newGaugeFamily("scrapecli_scrape_duration_seconds", "Duration of the last scrape of the target, including its analysis.")
+	bytes := newGaugeFamily("scrapecli_scrape_bytes", "Uncompressed size of the last scrape of the target.")
+	series := newGaugeFamily("scrapecli_scrape_series", "Number of series in the last scrape of the target.")
+	families := newGaugeFamily("scrapecli_scrape_families", "Number of metric families in the last scrape of the target.")
+	labels := newGaugeFamily("scrapecli_scrape_labels", "Number of label names in the last scrape of the target.")
+	familySeries := newGaugeFamily("scrapecli_family_series", "Number of series of a metric family. Families beyond the exported maximum are aggregated as "+otherFamily+".")
+	familyBytes := newGaugeFamily("scrapecli_family_bytes", "Size of a metric family in the scrape. Families beyond the exported maximum are aggregated as "+otherFamily+".")
+	labelValues := newGaugeFamily("scrapecli_label_distinct_values", "Number of distinct values of a label across the scrape, for the labels with the most values.")
+
+	for _, r := range results {
+		target := r.URL
+		success := 0.0
+		if r.Error == "" && r.Summary != nil {
+			success = 1
+		}
+		addGauge(up, success, "target", target)
+		addGauge(duration, r.DurationSeconds, "target", target)
+		if r.Summary == nil {
+			continue
+		}
+		s := r.Summary
+
+		metrics := append([]MetricSummary(nil), s.Metrics...)
+		sort.SliceStable(metrics, func(i, j int) bool {
+			if metrics[i].Cardinality == metrics[j].Cardinality {
+				return metrics[i].Name < metrics[j].Name
+			}
+			return metrics[i].Cardinality > metrics[j].Cardinality
+		})
+		total := 0
+		var otherSeries int
+		var otherBytes int64
+		for i, m := range metrics {
+			total += m.Cardinality
+			if i >= e.opts.MaxFamilies {
+				otherSeries += m.Cardinality
+				otherBytes += m.Size
+				continue
+			}
+			typ := strings.ToLower(m.Type)
+			addGauge(familySeries, float64(m.Cardinality), "target", target, "family", m.Name, "type", typ)
+			addGauge(familyBytes, float64(m.Size), "target", target, "family", m.Name, "type", typ)
+		}
+		if len(metrics) > e.opts.MaxFamilies {
+			addGauge(familySeries, float64(otherSeries), "target", target, "family", otherFamily, "type", "")
+			addGauge(familyBytes, float64(otherBytes), "target", target, "family", otherFamily, "type", "")
+		}
+
+		names := make([]string, 0, len(s.Summary.LabelValueCounts))
+		for name := range s.Summary.LabelValueCounts {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			ci, cj := s.Summary.LabelValueCounts[names[i]], s.Summary.LabelValueCounts[names[j]]
+			if ci == cj {
+				return names[i] < names[j]
+			}
+			return ci > cj
+		})
+		for _, name := range names[:min(e.opts.MaxLabels, len(names))] {
+			addGauge(labelValues, float64(s.Summary.LabelValueCounts[name]), "target", target, "label", name)
+		}
+
+		addGauge(bytes, float64(s.Summary.Bytes), "target", target)
+		addGauge(series, float64(total), "target", target)
+		addGauge(families, float64(len(s.Metrics)), "target", target)
+		addGauge(labels, float64(len(names)), "target", target)
+	}
+
+	var out []*dto.MetricFamily
+	for _, mf := range []*dto.MetricFamily{up, duration, bytes, series, families, labels, familySeries, familyBytes, labelValues} {
+		if len(mf.Metric) > 0 {
+			out = append(out, mf)
+		}
+	}
+	return out
+}
+
+func newGaugeFamily(name, help string) *dto.MetricFamily {
+	return &dto.MetricFamily{Name: proto.String(name), Help: proto.String(help), Type: dto.MetricType_GAUGE.Enum()}
+}
+
+// addGauge adds a series to mf with labels given as name, value pairs.
+func addGauge(mf *dto.MetricFamily, value float64, labels ...string) {
+	m := &dto.Metric{Gauge: &dto.Gauge{Value: proto.Float64(value)}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(labels[i]), Value: proto.String(labels[i+1])})
+	}
+	mf.Metric = append(mf.Metric, m)
+}
+
+// urlTargets turns URLs given on the command line into scrape targets.
+func urlTargets(urls []string, timeout time.Duration) ([]ScrapeTarget, error) {
+	config := &PromScrapeConfig{}
+	targets := make([]ScrapeTarget, 0, len(urls))
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid target URL %q", raw)
+		}
+		targets = append(targets, ScrapeTarget{
+			Instance: u.Host,
+			URL:      u.String(),
+			Labels:   map[string]string{"instance": u.Host},
+			config:   config,
+			timeout:  timeout,
+		})
+	}
+	return targets, nil
+}
+
+// runServe implements `scrapecli serve --targets URL,...`.
+func runServe(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", defaultServeListen, "Address to expose /metrics on")
+	var urls []string
+	fs.Func("targets", "Comma separated URLs to scrape (repeatable)", func(s string) error {
+		for _, u := range strings.Split(s, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		return nil
+	})
+	configFile := fs.String("config", "", "Prometheus configuration file to discover targets from")
+	interval := fs.Duration("interval", defaultServeInterval, "Interval between scrapes of all targets")
+	timeout := fs.Duration("scrape-timeout", defaultScrapeTimeout, "Timeout of scrapes of --targets URLs")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of targets scraped concurrently")
+	var opts ExporterOptions
+	fs.IntVar(&opts.MaxFamilies, "max-families", defaultServeMaxFamilies, "Families with the most series exported per target; others are aggregated")
+	fs.IntVar(&opts.MaxLabels, "max-labels", defaultServeMaxLabels, "Labels with the most distinct values exported per target")
+	var httpOpts httpFlags
+	httpOpts.register(fs)
+	headers := http.Header{}
+	fs.Func("H", "Header added to every request as 'Name: value' (repeatable)", func(s string) error {
+		name, value, err := parseHeaderFlag(s)
+		if err != nil {
+			return err
+		}
+		headers.Add(name, value)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (len(urls) == 0 && *configFile == "") || fs.NArg() > 0 {
+		return fmt.Errorf("usage: scrapecli serve --targets URL,... | --config FILE [flags]")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if opts.MaxFamilies < 0 || opts.MaxLabels < 0 {
+		return fmt.Errorf("--max-families and --max-labels must not be negative")
+	}
+
+	targets, err := urlTargets(urls, *timeout)
+	if err != nil {
+		return err
+	}
+	if *configFile != "" {
+		c, err := LoadPromConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		discovered, err := c.Targets()
+		if err != nil {
+			return err
+		}
+		targets = append(targets, discovered...)
+	}
+	if err := httpOpts.apply(targets); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	e := newExporter(targets, headers, *workers, opts)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	srv := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go e.run(ctx, *interval)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(out, "Serving metrics of %d targets on %s/metrics\n", len(targets), *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
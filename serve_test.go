@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter(t *testing.T) {
+	scrape := "# TYPE http_requests_total counter\n" +
+		"http_requests_total{code=\"200\",path=\"/a\"} 1\n" +
+		"http_requests_total{code=\"200\",path=\"/b\"} 1\n" +
+		"http_requests_total{code=\"500\",path=\"/c\"} 1\n" +
+		"# TYPE queue_length gauge\nqueue_length{queue=\"a\"} 1\nqueue_length{queue=\"b\"} 1\n" +
+		"# TYPE up gauge\nup 1\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(scrape))
+	}))
+	defer srv.Close()
+
+	targets, err := urlTargets([]string{srv.URL + "/metrics", srv.URL + "/missing"}, time.Second)
+	require.NoError(t, err)
+	e := newExporter(targets, nil, 2, ExporterOptions{MaxFamilies: 1, MaxLabels: 2})
+
+	// Nothing is exported before the first scrape
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Body.String())
+
+	e.scrape(t.Context())
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	body := rec.Body.String()
+
+	ok, missing := srv.URL+"/metrics", srv.URL+"/missing"
+	for _, line := range []string{
+		`scrapecli_scrape_success{target="` + ok + `"} 1`,
+		`scrapecli_scrape_success{target="` + missing + `"} 0`,
+		`scrapecli_scrape_series{target="` + ok + `"} 6`,
+		`scrapecli_scrape_families{target="` + ok + `"} 3`,
+		`scrapecli_scrape_labels{target="` + ok + `"} 3`,
+		`scrapecli_scrape_bytes{target="` + ok + `"} ` + strconv.Itoa(len(scrape)),
+		// Only the largest family is exported, the others are aggregated
+		`scrapecli_family_series{family="http_requests_total",target="` + ok + `",type="counter"} 3`,
+		`scrapecli_family_series{family="__other__",target="` + ok + `",type=""} 3`,
+		// Only the labels with the most distinct values are exported
+		`scrapecli_label_distinct_values{label="path",target="` + ok + `"} 3`,
+		`scrapecli_label_distinct_values{label="code",target="` + ok + `"} 2`,
+	} {
+		require.Contains(t, body, line+"\n")
+	}
+	require.NotContains(t, body, `family="queue_length"`)
+	require.NotContains(t, body, `label="queue"`)
+	require.Contains(t, body, `scrapecli_scrape_duration_seconds{target="`+missing+`"}`)
+	require.NotContains(t, body, `scrapecli_scrape_series{target="`+missing+`"}`)
+	require.Contains(t, body, "# TYPE scrapecli_family_bytes gauge\n")
+}
+
+func TestURLTargets(t *testing.T) {
+	targets, err := urlTargets([]string{"https://node1:9100/metrics?x=1"}, time.Second)
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	require.Equal(t, "node1:9100", targets[0].Instance)
+	require.Equal(t, "https://node1:9100/metrics?x=1", targets[0].URL)
+	require.NotNil(t, targets[0].config)
+
+	for _, u := range []string{"node1:9100", "ftp://node1/metrics", "http:///metrics"} {
+		_, err := urlTargets([]string{u}, time.Second)
+		require.Error(t, err, u)
+	}
+}
+
+func TestURLTargetsHTTPFlags(t *testing.T) {
+	var auth []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(testScrape))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ca.crt"), string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+	writeFile(t, filepath.Join(dir, "token"), "s3cret\n")
+
+	// The test server's certificate is unknown without the CA
+	targets, err := urlTargets([]string{srv.URL + "/metrics"}, time.Second)
+	require.NoError(t, err)
+	results := scrapeTargets(t.Context(), targets, nil, 1)
+	require.Contains(t, results[0].Error, "certificate")
+
+	targets, err = urlTargets([]string{srv.URL + "/metrics"}, time.Second)
+	require.NoError(t, err)
+	flags := httpFlags{CAFile: filepath.Join(dir, "ca.crt"), BearerTokenFile: filepath.Join(dir, "token")}
+	require.NoError(t, flags.apply(targets))
+	results = scrapeTargets(t.Context(), targets, nil, 1)
+	require.Empty(t, results[0].Error)
+	require.Len(t, results[0].Summary.Metrics, 1)
+	require.Equal(t, []string{"Bearer s3cret"}, auth)
+
+	require.Error(t, httpFlags{CertFile: "client.crt"}.apply(targets))
+	require.Error(t, httpFlags{ProxyURL: "://bad"}.apply(targets))
+}
+
+func TestRunServeUsage(t *testing.T) {
+	var out bytes.Buffer
+	require.Error(t, runServe(nil, &out))
+	require.Error(t, runServe([]string{"--targets", "http://a/metrics", "--interval", "0s"}, &out))
+	require.Error(t, runServe([]string{"--targets", "not a url"}, &out))
+	require.EqualError(t, runServe([]string{"--targets", "http://a/metrics", "--max-labels", "-1"}, &out), "--max-families and --max-labels must not be negative")
+	require.EqualError(t, runServe([]string{"--targets", "http://a/metrics", "--max-families", "-1"}, &out), "--max-families and --max-labels must not be negative")
+}
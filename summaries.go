@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// defaultSummaryBuckets is the number of buckets (including +Inf) of the
+// classic histogram summaries are compared to.
+const defaultSummaryBuckets = 10
+
+// SummaryAnalysis compares the series a summary family costs today with those
+// of an equivalent classic or native histogram.
+type SummaryAnalysis struct {
+	Family    string `json:"family"`
+	LabelSets int    `json:"label_sets"`
+	// Quantiles is the number of distinct quantiles across label sets.
+	Quantiles int `json:"quantiles"`
+	// Series counts quantiles plus _sum and _count per label set.
+	Series int `json:"series"`
+	// HistogramBuckets is the bucket count HistogramSeries is computed for.
+	HistogramBuckets int `json:"histogram_buckets"`
+	// HistogramSeries counts buckets plus _sum and _count per label set.
+	HistogramSeries int `json:"histogram_series"`
+	// NativeHistogramSeries counts one series per label set, since a native
+	// histogram sample holds all buckets, the sum and the count.
+	NativeHistogramSeries int `json:"native_histogram_series"`
+	// Drawbacks lists the aggregation problems specific to this family.
+	Drawbacks []string `json:"drawbacks,omitempty"`
+}
+
+// AnalyzeSummaries reports the cost of every summary family and of converting
+// it to a classic histogram with the given number of buckets or to a native
+// histogram. Results are sorted by series (descending), then family.
+func AnalyzeSummaries(mfs []*dto.MetricFamily, buckets int) []SummaryAnalysis {
+	if buckets <= 0 {
+		buckets = defaultSummaryBuckets
+	}
+
+	var analyses []SummaryAnalysis
+	for _, mf := range mfs {
+		if mf.GetType() != dto.MetricType_SUMMARY || len(mf.Metric) == 0 {
+			continue
+		}
+		a := SummaryAnalysis{Family: mf.GetName(), LabelSets: len(mf.Metric), HistogramBuckets: buckets}
+
+		quantiles := make(map[float64]struct{})
+		for _, m := range mf.Metric {
+			for _, q := range m.GetSummary().GetQuantile() {
+				quantiles[q.GetQuantile()] = struct{}{}
+			}
+			a.Series += len(m.GetSummary().GetQuantile()) + 2
+		}
+		a.Quantiles = len(quantiles)
+		a.HistogramSeries = a.LabelSets * (buckets + 2)
+		a.NativeHistogramSeries = a.LabelSets
+
+		if a.LabelSets > 1 {
+			a.Drawbacks = append(a.Drawbacks, fmt.Sprintf("quantiles of the %d label sets can't be aggregated (e.g. with sum by)", a.LabelSets))
+		}
+
+		analyses = append(analyses, a)
+	}
+	sort.Slice(analyses, func(i, j int) bool {
+		if analyses[i].Series == analyses[j].Series {
+			return analyses[i].Family < analyses[j].Family
+		}
+		return analyses[i].Series > analyses[j].Series
+	})
+	return analyses
+}
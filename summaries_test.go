@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeSummaries(t *testing.T) {
+	data := []byte(`# TYPE rpc_seconds summary
+rpc_seconds{service="a",quantile="0.5"} 1
+rpc_seconds{service="a",quantile="0.99"} 2
+rpc_seconds_sum{service="a"} 3
+rpc_seconds_count{service="a"} 2
+rpc_seconds{service="b",quantile="0.5"} 1
+rpc_seconds{service="b",quantile="0.9"} 1
+rpc_seconds{service="b",quantile="0.99"} 2
+rpc_seconds_sum{service="b"} 3
+rpc_seconds_count{service="b"} 2
+# TYPE gc_seconds summary
+gc_seconds_sum 1
+gc_seconds_count 1
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="+Inf"} 1
+latency_seconds_sum 1
+latency_seconds_count 1
+`)
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+
+	require.Equal(t, []SummaryAnalysis{
+		{
+			Family:                "rpc_seconds",
+			LabelSets:             2,
+			Quantiles:             3,
+			Series:                9,
+			HistogramBuckets:      5,
+			HistogramSeries:       14,
+			NativeHistogramSeries: 2,
+			Drawbacks:             []string{"quantiles of the 2 label sets can't be aggregated (e.g. with sum by)"},
+		},
+		{
+			Family:                "gc_seconds",
+			LabelSets:             1,
+			Series:                2,
+			HistogramBuckets:      5,
+			HistogramSeries:       7,
+			NativeHistogramSeries: 1,
+		},
+	}, AnalyzeSummaries(mfs, 5))
+
+	require.Equal(t, defaultSummaryBuckets, AnalyzeSummaries(mfs, 0)[0].HistogramBuckets)
+}
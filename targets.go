@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scrapeAcceptHeader asks for the Prometheus text format, which scrapecli
+// summarizes byte for byte.
+const scrapeAcceptHeader = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+
+// TargetSummary is the result of scraping one target. Error is set if the
+// target could not be scraped or its response could not be parsed.
+type TargetSummary struct {
+	ScrapeTarget
+	Error   string         `json:"error,omitempty"`
+	Summary *ScrapeSummary `json:"summary,omitempty"`
+	// DurationSeconds is how long fetching and summarizing the target took.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+}
+
+// scrapeTargets scrapes the targets concurrently with at most workers
+// requests in flight. The given headers are added to every request, replacing
+// those of the scrape configs. Results are in the order of targets.
+func scrapeTargets(ctx context.Context, targets []ScrapeTarget, headers http.Header, workers int) []TargetSummary {
+	results := make([]TargetSummary, len(targets))
+	clients := make(map[*PromScrapeConfig]*http.Client)
+	clientErrs := make(map[*PromScrapeConfig]error)
+	for _, t := range targets {
+		if _, ok := clients[t.config]; !ok {
+			clients[t.config], clientErrs[t.config] = newScrapeClient(&t.config.PromHTTPConfig)
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				t := targets[i]
+				results[i] = TargetSummary{ScrapeTarget: t}
+				if err := clientErrs[t.config]; err != nil {
+					results[i].Error = err.Error()
+					continue
+				}
+				start := time.Now()
+				s, err := scrapeTarget(ctx, clients[t.config], t, headers)
+				results[i].DurationSeconds = time.Since(start).Seconds()
+				if err != nil {
+					results[i].Error = err.Error()
+					continue
+				}
+				results[i].Summary = &s
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// scrapeTarget fetches a target's metrics the way Prometheus does and
+// summarizes them.
+func scrapeTarget(ctx context.Context, client *http.Client, t ScrapeTarget, headers http.Header) (ScrapeSummary, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return ScrapeSummary{}, err
+	}
+	req.Header.Set("Accept", scrapeAcceptHeader)
+	// Requesting gzip explicitly keeps the response compressed, so the
+	// summary reports the transfer size
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("User-Agent", "scrapecli")
+	if t.timeout > 0 {
+		req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", strconv.FormatFloat(t.timeout.Seconds(), 'f', -1, 64))
+	}
+	if err := t.config.setRequestHeaders(req); err != nil {
+		return ScrapeSummary{}, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ScrapeSummary{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ScrapeSummary{}, fmt.Errorf("server returned HTTP status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ScrapeSummary{}, err
+	}
+	return summarizeCompressed(ctx, data)
+}
+
+// runTargets implements `scrapecli targets --config prometheus.yml`.
+func runTargets(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("targets", flag.ContinueOnError)
+	configFile := fs.String("config", "", "Prometheus configuration file")
+	var outputFormat string
+	fs.StringVar(&outputFormat, "output-format", "terminal", "Output format: terminal or json")
+	fs.StringVar(&outputFormat, "o", "terminal", "Shorthand for --output-format")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of targets scraped concurrently")
+	dryRun := fs.Bool("dry-run", false, "List the targets after relabeling without scraping them")
+	var httpOpts httpFlags
+	httpOpts.register(fs)
+	headers := http.Header{}
+	fs.Func("H", "Header added to every request as 'Name: value' (repeatable)", func(s string) error {
+		name, value, err := parseHeaderFlag(s)
+		if err != nil {
+			return err
+		}
+		headers.Add(name, value)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configFile == "" || fs.NArg() > 0 {
+		return fmt.Errorf("usage: scrapecli targets --config FILE [flags]")
+	}
+
+	c, err := LoadPromConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	targets, err := c.Targets()
+	if err != nil {
+		return err
+	}
+	if err := httpOpts.apply(targets); err != nil {
+		return err
+	}
+
+	results := make([]TargetSummary, 0, len(targets))
+	if *dryRun {
+		for _, t := range targets {
+			results = append(results, TargetSummary{ScrapeTarget: t})
+		}
+	} else {
+		results = scrapeTargets(context.Background(), targets, headers, *workers)
+	}
+
+	switch strings.ToLower(outputFormat) {
+	case "json":
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	default:
+		_, err := io.WriteString(out, FormatTargetSummariesTerminal(results))
+		return err
+	}
+}
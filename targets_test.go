@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestPromConfigTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "sd/nodes.json"), `[{"targets": ["node2:9100", "node3:9100"], "labels": {"env": "dev"}}]`)
+	writeFile(t, filepath.Join(dir, "sd/more.yml"), "- targets: ['node4:9100']\n  labels:\n    env: prod\n")
+	writeFile(t, filepath.Join(dir, "prometheus.yml"), `
+global:
+  scrape_timeout: 5s
+scrape_configs:
+  - job_name: node
+    static_configs:
+      - targets: ['node1:9100']
+        labels:
+          env: prod
+    file_sd_configs:
+      - files: ['sd/*.json', 'sd/*.yml']
+    relabel_configs:
+      - source_labels: [env]
+        regex: prod
+        action: keep
+      - source_labels: [__address__]
+        regex: '([^:]+):\d+'
+        target_label: instance
+  - job_name: blackbox
+    metrics_path: /probe
+    scheme: https
+    scrape_timeout: 2s
+    params:
+      module: [http_2xx]
+    static_configs:
+      - targets: ['example.com']
+    relabel_configs:
+      - source_labels: [__address__]
+        target_label: __param_target
+      - target_label: __address__
+        replacement: blackbox:9115
+`)
+
+	c, err := LoadPromConfig(filepath.Join(dir, "prometheus.yml"))
+	require.NoError(t, err)
+	targets, err := c.Targets()
+	require.NoError(t, err)
+	require.Len(t, targets, 3)
+
+	require.Equal(t, "blackbox", targets[0].Job)
+	require.Equal(t, "blackbox:9115", targets[0].Instance)
+	require.Equal(t, "https://blackbox:9115/probe?module=http_2xx&target=example.com", targets[0].URL)
+	require.Equal(t, map[string]string{"job": "blackbox", "instance": "blackbox:9115"}, targets[0].Labels)
+	require.Equal(t, "2s", (targets[0].timeout).String())
+
+	require.Equal(t, "node", targets[1].Job)
+	require.Equal(t, "node1", targets[1].Instance)
+	require.Equal(t, "http://node1:9100/metrics", targets[1].URL)
+	require.Equal(t, map[string]string{"job": "node", "instance": "node1", "env": "prod"}, targets[1].Labels)
+	require.Equal(t, "5s", (targets[1].timeout).String())
+
+	require.Equal(t, "node4", targets[2].Instance)
+}
+
+func TestLoadPromConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"missing_job.yml":   "scrape_configs:\n  - static_configs: []\n",
+		"duplicate_job.yml": "scrape_configs:\n  - job_name: a\n  - job_name: a\n",
+		"bad_relabel.yml":   "scrape_configs:\n  - job_name: a\n    relabel_configs:\n      - action: nope\n",
+		"bad_yaml.yml":      "scrape_configs: [\n",
+	} {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, content)
+		_, err := LoadPromConfig(path)
+		require.Error(t, err, name)
+	}
+}
+
+func TestRunTargets(t *testing.T) {
+	scrape := "# TYPE up gauge\nup 1\n# TYPE http_requests_total counter\nhttp_requests_total{code=\"200\"} 1\nhttp_requests_total{code=\"500\"} 1\n"
+	var gotHeaders http.Header
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "prom" || pass != "s3cret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			gotHeaders = r.Header.Clone()
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write([]byte(scrape))
+			_ = zw.Close()
+		case "/federate":
+			if r.Header.Get("Authorization") != "Bearer t0ken" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			gotQuery = r.URL.Query()
+			_, _ = w.Write([]byte(scrape))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "secrets/password"), "s3cret\n")
+	writeFile(t, filepath.Join(dir, "prometheus.yml"), `
+scrape_configs:
+  - job_name: app
+    basic_auth:
+      username: prom
+      password_file: secrets/password
+    static_configs:
+      - targets: ['`+addr+`']
+  - job_name: federate
+    metrics_path: /federate
+    params:
+      'match[]': ['{job="app"}']
+    bearer_token: t0ken
+    static_configs:
+      - targets: ['`+addr+`']
+  - job_name: missing
+    metrics_path: /nope
+    static_configs:
+      - targets: ['`+addr+`']
+`)
+
+	var out bytes.Buffer
+	require.NoError(t, runTargets([]string{"--config", filepath.Join(dir, "prometheus.yml"), "-o", "json"}, &out))
+	var results []TargetSummary
+	require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	require.Len(t, results, 3)
+
+	app := results[0]
+	require.Equal(t, "app", app.Job)
+	require.Equal(t, addr, app.Instance)
+	require.Empty(t, app.Error)
+	require.NotNil(t, app.Summary)
+	require.Len(t, app.Summary.Metrics, 2)
+	require.Equal(t, int64(len(scrape)), app.Summary.Summary.Bytes)
+	require.Equal(t, encodingGzip, app.Summary.Summary.Encoding)
+	require.Equal(t, "10", gotHeaders.Get("X-Prometheus-Scrape-Timeout-Seconds"))
+	require.Contains(t, gotHeaders.Get("Accept"), "text/plain")
+
+	federate := results[1]
+	require.Empty(t, federate.Error)
+	require.NotNil(t, federate.Summary)
+	require.Equal(t, []string{`{job="app"}`}, gotQuery["match[]"])
+
+	missing := results[2]
+	require.Contains(t, missing.Error, "404")
+	require.Nil(t, missing.Summary)
+
+	// The terminal output groups targets by job
+	out.Reset()
+	require.NoError(t, runTargets([]string{"--config", filepath.Join(dir, "prometheus.yml")}, &out))
+	require.Contains(t, out.String(), "(1 target):")
+	require.Contains(t, out.String(), "3 series, 2 families")
+	require.Contains(t, out.String(), "error: server returned HTTP status 404")
+}
+
+func TestRunTargetsDryRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "prometheus.yml"), "scrape_configs:\n  - job_name: a\n    static_configs:\n      - targets: ['127.0.0.1:1']\n")
+
+	var out bytes.Buffer
+	require.NoError(t, runTargets([]string{"--config", filepath.Join(dir, "prometheus.yml"), "--dry-run", "-o", "json"}, &out))
+	var results []TargetSummary
+	require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	require.Len(t, results, 1)
+	require.Equal(t, "http://127.0.0.1:1/metrics", results[0].URL)
+	require.Empty(t, results[0].Error)
+	require.Nil(t, results[0].Summary)
+
+	require.Error(t, runTargets(nil, &out))
+}
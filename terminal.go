@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"golang.org/x/term"
+)
+
+// Values of the --color flag.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// minNameWidth is the narrowest a name column is truncated to on narrow
+// terminals.
+const minNameWidth = 10
+
+// configureColor enables or disables colored output. In auto mode colors are
+// used only if f is a terminal and the NO_COLOR environment variable is not
+// set (see https://no-color.org); always and never override both.
+func configureColor(mode string, f *os.File) error {
+	switch mode {
+	case colorAlways:
+		color.NoColor = false
+	case colorNever:
+		color.NoColor = true
+	case colorAuto, "":
+		color.NoColor = os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !isTerminal(f)
+	default:
+		return fmt.Errorf("invalid color mode %q (expected %s, %s or %s)", mode, colorAuto, colorAlways, colorNever)
+	}
+	return nil
+}
+
+// terminalWidth returns the width of the terminal f is connected to, or 0 if
+// f is not a terminal (e.g. a pipe). The COLUMNS environment variable is used
+// if the size cannot be queried.
+func terminalWidth(f *os.File) int {
+	if !isTerminal(f) {
+		return 0
+	}
+	if w, _, err := term.GetSize(int(f.Fd())); err == nil && w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 0
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// runeLen returns the number of runes in s, used as its display width.
+func runeLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with "…".
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(runes[:max(n, 0)])
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// fitLabels returns how many of labels fit into width columns when joined with
+// ", ", reserving room for a trailing ", +N more". At least one label is
+// always shown.
+func fitLabels(labels []string, width int) int {
+	if runeLen(strings.Join(labels, ", ")) <= width {
+		return len(labels)
+	}
+	used := 0
+	for i, l := range labels {
+		used += runeLen(l)
+		if i > 0 {
+			used += len(", ")
+		}
+		if used+len(fmt.Sprintf(", +%d more", len(labels)-i-1)) > width {
+			return max(i, 1)
+		}
+	}
+	return len(labels)
+}
+
+// wrapText breaks s into lines of at most width runes at spaces. Words longer
+// than width are kept whole. A width of 0 disables wrapping.
+func wrapText(s string, width int) []string {
+	if width <= 0 || runeLen(s) <= width {
+		return []string{s}
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		switch {
+		case line == "":
+			line = word
+		case runeLen(line)+1+runeLen(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureColor(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+
+	// A regular file is never a terminal
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	require.NoError(t, configureColor(colorAlways, f))
+	require.False(t, color.NoColor)
+	require.NoError(t, configureColor(colorNever, f))
+	require.True(t, color.NoColor)
+
+	color.NoColor = false
+	require.NoError(t, configureColor(colorAuto, f))
+	require.True(t, color.NoColor, "auto disables colors when not writing to a terminal")
+
+	t.Setenv("NO_COLOR", "1")
+	require.NoError(t, configureColor(colorAlways, f))
+	require.False(t, color.NoColor, "always overrides NO_COLOR")
+
+	require.Error(t, configureColor("sometimes", f))
+	require.Equal(t, 0, terminalWidth(f))
+}
+
+func TestWrapText(t *testing.T) {
+	require.Equal(t, []string{"one two", "three", "supercalifragilistic", "four"}, wrapText("one two three supercalifragilistic four", 8))
+	require.Equal(t, []string{"fits"}, wrapText("fits", 4))
+	require.Equal(t, []string{"no  wrapping"}, wrapText("no  wrapping", 0))
+}
+
+func TestFitLabels(t *testing.T) {
+	labels := []string{"aa", "bb", "cc", "dd", "ee", "ff"}
+	require.Equal(t, 6, fitLabels(labels, 22)) // "aa, bb, cc, dd, ee, ff"
+	require.Equal(t, 1, fitLabels(labels, 14)) // "aa, +5 more"
+	require.Equal(t, 2, fitLabels(labels, 15)) // "aa, bb, +4 more"
+	require.Equal(t, 1, fitLabels(labels, 0), "at least one label is shown")
+	require.Equal(t, "abc…", truncateRunes("abcdef", 4))
+	require.Equal(t, "äöü", truncateRunes("äöü", 3))
+}
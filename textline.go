@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rawLabel is a label pair of a sample line. ValueStart and ValueEnd are the
+// byte offsets of the escaped value (without the quotes) within the line.
+type rawLabel struct {
+	Name       string
+	Value      string
+	ValueStart int
+	ValueEnd   int
+}
+
+// sampleLine is a tokenized sample line of the text exposition format. Values
+// and timestamps are kept as written so that the line can be rewritten without
+// changing anything but the parts that are replaced.
+type sampleLine struct {
+	Name      string
+	Labels    []rawLabel
+	Value     string
+	Timestamp string
+
+	// Optional OpenMetrics exemplar following the sample (`# {...} v [ts]`).
+	// ExemplarStart is the byte offset of its '#'.
+	HasExemplar       bool
+	ExemplarStart     int
+	ExemplarLabels    []rawLabel
+	ExemplarValue     string
+	ExemplarTimestamp string
+}
+
+// parseSampleLine tokenizes a single sample line (not a comment or blank
+// line). The metric name may also be given as a quoted string inside the
+// braces, as allowed for UTF-8 names.
+func parseSampleLine(line string) (sampleLine, error) {
+	t := &lineTokenizer{line: line}
+	var s sampleLine
+
+	t.skipBlank()
+	s.Name = t.name()
+	t.skipBlank()
+	if t.peek() == '{' {
+		labels, quotedName, err := t.labels()
+		if err != nil {
+			return s, err
+		}
+		s.Labels = labels
+		if quotedName != "" {
+			if s.Name != "" {
+				return s, t.errorf("metric name given twice")
+			}
+			s.Name = quotedName
+		}
+	}
+	if s.Name == "" {
+		return s, t.errorf("missing metric name")
+	}
+
+	t.skipBlank()
+	s.Value = t.field()
+	if s.Value == "" {
+		return s, t.errorf("missing value")
+	}
+	t.skipBlank()
+	if t.peek() != '#' {
+		s.Timestamp = t.field()
+		t.skipBlank()
+	}
+
+	if t.peek() == '#' {
+		s.ExemplarStart = t.pos
+		t.pos++
+		t.skipBlank()
+		if t.peek() != '{' {
+			return s, t.errorf("expected exemplar labels")
+		}
+		labels, _, err := t.labels()
+		if err != nil {
+			return s, err
+		}
+		s.HasExemplar = true
+		s.ExemplarLabels = labels
+		t.skipBlank()
+		s.ExemplarValue = t.field()
+		if s.ExemplarValue == "" {
+			return s, t.errorf("missing exemplar value")
+		}
+		t.skipBlank()
+		s.ExemplarTimestamp = t.field()
+		t.skipBlank()
+	}
+
+	if t.pos < len(line) {
+		return s, t.errorf("unexpected trailing input %q", line[t.pos:])
+	}
+	return s, nil
+}
+
+type lineTokenizer struct {
+	line string
+	pos  int
+}
+
+// labels consumes a brace-enclosed label set. A lone quoted string is returned
+// as the metric name.
+func (t *lineTokenizer) labels() ([]rawLabel, string, error) {
+	var labels []rawLabel
+	var quotedName string
+
+	t.pos++ // '{'
+	for {
+		t.skipBlank()
+		if t.peek() == '}' {
+			t.pos++
+			return labels, quotedName, nil
+		}
+
+		var name string
+		if t.peek() == '"' {
+			n, _, _, err := t.quoted()
+			if err != nil {
+				return nil, "", err
+			}
+			t.skipBlank()
+			if c := t.peek(); c == ',' || c == '}' {
+				quotedName = n
+				if c == ',' {
+					t.pos++
+				}
+				continue
+			}
+			name = n
+		} else {
+			name = t.name()
+			if name == "" {
+				return nil, "", t.errorf("expected label name")
+			}
+		}
+
+		t.skipBlank()
+		if t.peek() != '=' {
+			return nil, "", t.errorf("expected '=' after label name %q", name)
+		}
+		t.pos++
+		t.skipBlank()
+		if t.peek() != '"' {
+			return nil, "", t.errorf("expected quoted value for label %q", name)
+		}
+		value, start, end, err := t.quoted()
+		if err != nil {
+			return nil, "", err
+		}
+		labels = append(labels, rawLabel{Name: name, Value: value, ValueStart: start, ValueEnd: end})
+
+		t.skipBlank()
+		switch t.peek() {
+		case ',':
+			t.pos++
+		case '}':
+		default:
+			return nil, "", t.errorf("expected ',' or '}' after label %q", name)
+		}
+	}
+}
+
+// quoted consumes a double-quoted string and returns its unescaped value and
+// the offsets of its escaped content.
+func (t *lineTokenizer) quoted() (string, int, int, error) {
+	t.pos++ // opening quote
+	start := t.pos
+	var b strings.Builder
+	for t.pos < len(t.line) {
+		c := t.line[t.pos]
+		switch c {
+		case '"':
+			end := t.pos
+			t.pos++
+			return b.String(), start, end, nil
+		case '\\':
+			if t.pos+1 >= len(t.line) {
+				return "", 0, 0, t.errorf("unterminated escape sequence")
+			}
+			switch e := t.line[t.pos+1]; e {
+			case '\\', '"':
+				b.WriteByte(e)
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				return "", 0, 0, t.errorf("invalid escape sequence \\%c", e)
+			}
+			t.pos += 2
+		default:
+			b.WriteByte(c)
+			t.pos++
+		}
+	}
+	return "", 0, 0, t.errorf("unterminated quoted string")
+}
+
+// name consumes a legacy metric or label name.
+func (t *lineTokenizer) name() string {
+	start := t.pos
+	for t.pos < len(t.line) {
+		c := t.line[t.pos]
+		if c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (t.pos > start && c >= '0' && c <= '9') {
+			t.pos++
+			continue
+		}
+		break
+	}
+	return t.line[start:t.pos]
+}
+
+// field consumes everything up to the next blank.
+func (t *lineTokenizer) field() string {
+	start := t.pos
+	for t.pos < len(t.line) && t.line[t.pos] != ' ' && t.line[t.pos] != '\t' {
+		t.pos++
+	}
+	return t.line[start:t.pos]
+}
+
+func (t *lineTokenizer) skipBlank() {
+	for t.pos < len(t.line) && (t.line[t.pos] == ' ' || t.line[t.pos] == '\t' || t.line[t.pos] == '\r') {
+		t.pos++
+	}
+}
+
+func (t *lineTokenizer) peek() byte {
+	if t.pos >= len(t.line) {
+		return 0
+	}
+	return t.line[t.pos]
+}
+
+func (t *lineTokenizer) errorf(format string, args ...any) error {
+	return fmt.Errorf("column %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
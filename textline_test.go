@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSampleLine(t *testing.T) {
+	line := `http_requests_total{code="200", path="/a \"b\"\\c",} 1027 1395066363000 # {trace_id="abc"} 1.5 1395066363.5`
+	s, err := parseSampleLine(line)
+	require.NoError(t, err)
+	require.Equal(t, "http_requests_total", s.Name)
+	require.Equal(t, "1027", s.Value)
+	require.Equal(t, "1395066363000", s.Timestamp)
+	require.Len(t, s.Labels, 2)
+	require.Equal(t, rawLabel{Name: "path", Value: `/a "b"\c`, ValueStart: 38, ValueEnd: 49}, s.Labels[1])
+	require.Equal(t, `/a \"b\"\\c`, line[s.Labels[1].ValueStart:s.Labels[1].ValueEnd])
+
+	require.True(t, s.HasExemplar)
+	require.Equal(t, "trace_id", s.ExemplarLabels[0].Name)
+	require.Equal(t, "abc", s.ExemplarLabels[0].Value)
+	require.Equal(t, "1.5", s.ExemplarValue)
+	require.Equal(t, "1395066363.5", s.ExemplarTimestamp)
+
+	s, err = parseSampleLine(`{"my.metric", "my.label"="x"} +Inf`)
+	require.NoError(t, err)
+	require.Equal(t, "my.metric", s.Name)
+	require.Equal(t, "my.label", s.Labels[0].Name)
+	require.Equal(t, "+Inf", s.Value)
+	require.False(t, s.HasExemplar)
+
+	for _, bad := range []string{`foo`, `{a="b"} 1`, `foo{a="b} 1`, `foo{a=b} 1`, `foo{a="\x"} 1`, `foo 1 2 3`, `foo 1 # 2`} {
+		_, err := parseSampleLine(bad)
+		require.Error(t, err, "expected %q to be rejected", bad)
+	}
+}
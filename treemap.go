@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sort"
+)
+
+// treemapRect is a laid out node of a treemap.
+type treemapRect struct {
+	Node       *PrefixNode
+	X, Y, W, H float64
+}
+
+// squarify lays out nodes within the given rectangle using the squarified
+// treemap algorithm (Bruls, Huizing, van Wijk), so that each node's area is
+// proportional to value(node) and rectangles are as close to squares as
+// possible. Nodes with a zero value are skipped.
+func squarify(nodes []*PrefixNode, x, y, w, h float64, value func(*PrefixNode) float64) []treemapRect {
+	total := 0.0
+	for _, n := range nodes {
+		total += value(n)
+	}
+	if total <= 0 || w <= 0 || h <= 0 {
+		return nil
+	}
+
+	type item struct {
+		node *PrefixNode
+		area float64
+	}
+	scale := w * h / total
+	items := make([]item, 0, len(nodes))
+	for _, n := range nodes {
+		if v := value(n); v > 0 {
+			items = append(items, item{node: n, area: v * scale})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].area > items[j].area })
+
+	// worst returns the highest aspect ratio within a row laid out along side.
+	worst := func(row []item, side float64) float64 {
+		sum, lo, hi := 0.0, row[0].area, row[0].area
+		for _, it := range row {
+			sum += it.area
+			lo = min(lo, it.area)
+			hi = max(hi, it.area)
+		}
+		return max(side*side*hi/(sum*sum), (sum*sum)/(side*side*lo))
+	}
+
+	var out []treemapRect
+	place := func(row []item) {
+		sum := 0.0
+		for _, it := range row {
+			sum += it.area
+		}
+		if w >= h {
+			// Fill a column at the left
+			cw := sum / h
+			cy := y
+			for _, it := range row {
+				ch := it.area / cw
+				out = append(out, treemapRect{Node: it.node, X: x, Y: cy, W: cw, H: ch})
+				cy += ch
+			}
+			x, w = x+cw, w-cw
+		} else {
+			// Fill a row at the top
+			rh := sum / w
+			cx := x
+			for _, it := range row {
+				cw := it.area / rh
+				out = append(out, treemapRect{Node: it.node, X: cx, Y: y, W: cw, H: rh})
+				cx += cw
+			}
+			y, h = y+rh, h-rh
+		}
+	}
+
+	var row []item
+	for i := 0; i < len(items); {
+		side := min(w, h)
+		if len(row) == 0 || worst(append(row[:len(row):len(row)], items[i]), side) <= worst(row, side) {
+			row = append(row, items[i])
+			i++
+			continue
+		}
+		place(row)
+		row = nil
+	}
+	if len(row) > 0 {
+		place(row)
+	}
+	return out
+}
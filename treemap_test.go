@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSquarify(t *testing.T) {
+	nodes := []*PrefixNode{
+		{Name: "a", Series: 6}, {Name: "b", Series: 6}, {Name: "c", Series: 4},
+		{Name: "d", Series: 3}, {Name: "e", Series: 2}, {Name: "f", Series: 2},
+		{Name: "g", Series: 1}, {Name: "zero", Series: 0},
+	}
+	value := func(n *PrefixNode) float64 { return float64(n.Series) }
+
+	rects := squarify(nodes, 10, 20, 6, 4, value)
+	require.Len(t, rects, 7, "zero-valued nodes are skipped")
+
+	total := 0.0
+	for _, r := range rects {
+		// Area is proportional to the value: 24 units of value on 24 units of area
+		require.InDelta(t, value(r.Node), r.W*r.H, 1e-9, "area of %s", r.Node.Name)
+		require.GreaterOrEqual(t, r.X, 10-1e-9)
+		require.GreaterOrEqual(t, r.Y, 20-1e-9)
+		require.LessOrEqual(t, r.X+r.W, 16+1e-9)
+		require.LessOrEqual(t, r.Y+r.H, 24+1e-9)
+		total += r.W * r.H
+	}
+	require.InDelta(t, 24.0, total, 1e-9)
+
+	// Rectangles must not overlap
+	for i := range rects {
+		for j := i + 1; j < len(rects); j++ {
+			a, b := rects[i], rects[j]
+			overlapW := min(a.X+a.W, b.X+b.W) - max(a.X, b.X)
+			overlapH := min(a.Y+a.H, b.Y+b.H) - max(a.Y, b.Y)
+			require.False(t, overlapW > 1e-9 && overlapH > 1e-9, "%s and %s overlap", a.Node.Name, b.Node.Name)
+		}
+	}
+
+	require.Nil(t, squarify(nodes[7:], 0, 0, 10, 10, value))
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Checks reported by ValidateScrape.
+const (
+	checkSyntax               = "syntax"
+	checkDuplicateSeries      = "duplicate_series"
+	checkDuplicateLabel       = "duplicate_label"
+	checkDuplicateMetadata    = "duplicate_metadata"
+	checkConflictingMetadata  = "conflicting_metadata"
+	checkMetadataAfterSamples = "metadata_after_samples"
+	checkSplitFamily          = "split_family"
+)
+
+// familySuffixes lists the sample name suffixes belonging to a family of the
+// given type, e.g. `foo_bucket` belongs to the histogram `foo`.
+var familySuffixes = map[string][]string{
+	"counter":        {"_total", "_created"},
+	"histogram":      {"_bucket", "_sum", "_count", "_created"},
+	"gaugehistogram": {"_bucket", "_gsum", "_gcount"},
+	"summary":        {"_sum", "_count", "_created"},
+	"info":           {"_info"},
+}
+
+// ValidationIssue is a structural problem of a scrape at a given (1-based)
+// line. RelatedLine points to an earlier line involved, e.g. the first
+// occurrence of a duplicate series.
+type ValidationIssue struct {
+	Line        int    `json:"line"`
+	Check       string `json:"check"`
+	Message     string `json:"message"`
+	RelatedLine int    `json:"related_line,omitempty"`
+}
+
+// ValidateScrape checks the text exposition format in data line by line for
+// problems that make Prometheus reject samples: duplicate series, label names
+// repeated within a series, repeated or conflicting HELP/TYPE lines, metadata
+// following samples and families split across non-contiguous blocks. Unlike
+// decoding with expfmt it continues after the first problem, so all issues
+// are reported in line order.
+func ValidateScrape(data []byte) []ValidationIssue {
+	v := &validator{
+		types:      make(map[string]string),
+		typeLines:  make(map[string]int),
+		helpLines:  make(map[string]int),
+		series:     make(map[string]int),
+		hasSamples: make(map[string]bool),
+		blockEnds:  make(map[string]int),
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		v.line(i+1, line)
+	}
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		return v.issues[i].Line < v.issues[j].Line
+	})
+	return v.issues
+}
+
+type validator struct {
+	types      map[string]string // family name -> declared type
+	typeLines  map[string]int
+	helpLines  map[string]int
+	series     map[string]int // series key -> first line
+	hasSamples map[string]bool
+
+	// current is the family of the block being read; blockEnds holds the last
+	// line of every family's most recent, finished block.
+	current     string
+	currentLast int
+	blockEnds   map[string]int
+
+	issues []ValidationIssue
+}
+
+func (v *validator) report(line int, check string, related int, format string, args ...any) {
+	v.issues = append(v.issues, ValidationIssue{Line: line, Check: check, Message: fmt.Sprintf(format, args...), RelatedLine: related})
+}
+
+func (v *validator) line(n int, line string) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return
+	}
+	if strings.HasPrefix(trimmed, "#") {
+		v.comment(n, trimmed)
+		return
+	}
+
+	s, err := parseSampleLine(line)
+	if err != nil {
+		v.report(n, checkSyntax, 0, "%v", err)
+		return
+	}
+
+	family := v.familyOf(s.Name)
+	v.enter(n, family)
+	v.hasSamples[family] = true
+
+	seen := make(map[string]bool, len(s.Labels))
+	pairs := make([]string, 0, len(s.Labels))
+	for _, l := range s.Labels {
+		if seen[l.Name] {
+			v.report(n, checkDuplicateLabel, 0, "label %q repeated in series of %s", l.Name, s.Name)
+		}
+		seen[l.Name] = true
+		pairs = append(pairs, l.Name+"="+strconv.Quote(l.Value))
+	}
+	sort.Strings(pairs)
+	key := s.Name
+	if len(pairs) > 0 {
+		key += "{" + strings.Join(pairs, ",") + "}"
+	}
+	if first, ok := v.series[key]; ok {
+		v.report(n, checkDuplicateSeries, first, "series %s already exposed on line %d", key, first)
+		return
+	}
+	v.series[key] = n
+}
+
+// comment checks HELP and TYPE lines; other comments are ignored.
+func (v *validator) comment(n int, line string) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "#" || (fields[1] != "HELP" && fields[1] != "TYPE") {
+		return
+	}
+	keyword := fields[1]
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[1:]), keyword))
+	name, rest := metadataName(rest)
+	if name == "" {
+		v.report(n, checkSyntax, 0, "%s line without metric name", keyword)
+		return
+	}
+
+	v.enter(n, name)
+	if v.hasSamples[name] {
+		v.report(n, checkMetadataAfterSamples, 0, "%s for %s follows its samples", keyword, name)
+	}
+
+	if keyword == "HELP" {
+		if first, ok := v.helpLines[name]; ok {
+			v.report(n, checkDuplicateMetadata, first, "HELP for %s repeated (first on line %d)", name, first)
+			return
+		}
+		v.helpLines[name] = n
+		return
+	}
+
+	typ := strings.ToLower(strings.TrimSpace(rest))
+	if first, ok := v.typeLines[name]; ok {
+		if v.types[name] != typ {
+			v.report(n, checkConflictingMetadata, first, "TYPE %s for %s conflicts with TYPE %s on line %d", typ, name, v.types[name], first)
+		} else {
+			v.report(n, checkDuplicateMetadata, first, "TYPE for %s repeated (first on line %d)", name, first)
+		}
+		return
+	}
+	v.types[name] = typ
+	v.typeLines[name] = n
+}
+
+// enter records that line n belongs to family and reports it if the family
+// had a block before that was interrupted by another family.
+func (v *validator) enter(n int, family string) {
+	if family == v.current {
+		v.currentLast = n
+		return
+	}
+	if v.current != "" {
+		v.blockEnds[v.current] = v.currentLast
+	}
+	if end, ok := v.blockEnds[family]; ok {
+		v.report(n, checkSplitFamily, end, "family %s continues after other families (previous block ended on line %d)", family, end)
+	}
+	v.current, v.currentLast = family, n
+}
+
+// familyOf returns the family a sample name belongs to, taking the suffixes
+// of declared histograms, summaries, counters and infos into account.
+func (v *validator) familyOf(name string) string {
+	return declaredFamily(v.types, name)
+}
+
+// declaredFamily returns the family a sample name belongs to given the types
+// declared so far (family name -> lowercase type).
+func declaredFamily(types map[string]string, name string) string {
+	if _, ok := types[name]; ok {
+		return name
+	}
+	for i := strings.LastIndexByte(name, '_'); i > 0; i = strings.LastIndexByte(name[:i], '_') {
+		family, suffix := name[:i], name[i:]
+		typ, ok := types[family]
+		if ok && slices.Contains(familySuffixes[typ], suffix) {
+			return family
+		}
+	}
+	return name
+}
+
+// metadataName splits the metric name off a HELP or TYPE line's remainder.
+// The name may be quoted, as allowed for UTF-8 names.
+func metadataName(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", s
+		}
+		name, _ := strconv.Unquote(quoted)
+		return name, s[len(quoted):]
+	}
+	name, rest, _ := strings.Cut(s, " ")
+	return name, rest
+}
+
+// FormatValidationTerminal returns a human-readable list of issues.
+func FormatValidationTerminal(issues []ValidationIssue) string {
+	var b strings.Builder
+
+	bold := color.New(color.Bold).SprintFunc()
+	yellow := color.New(color.FgHiYellow).SprintFunc()
+	red := color.New(color.FgHiRed).SprintFunc()
+
+	b.WriteString(bold("## Validation") + "\n\n")
+	if len(issues) == 0 {
+		b.WriteString("No problems found.\n")
+		return b.String()
+	}
+	for _, issue := range issues {
+		b.WriteString(fmt.Sprintf("%s: %s: %s\n", yellow(fmt.Sprintf("line %d", issue.Line)), red(issue.Check), issue.Message))
+	}
+	problemWord := "problems"
+	if len(issues) == 1 {
+		problemWord = "problem"
+	}
+	b.WriteString(fmt.Sprintf("\n%d %s found.\n", len(issues), problemWord))
+	return b.String()
+}
+
+// runValidate implements `scrapecli validate [FILE]`. It reads stdin if no
+// file (or "-") is given and fails if any issue was found.
+func runValidate(args []string, stdin io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var outputFormat string
+	fs.StringVar(&outputFormat, "output-format", "terminal", "Output format: terminal or json")
+	fs.StringVar(&outputFormat, "o", "terminal", "Shorthand for --output-format")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: scrapecli validate [flags] [FILE]")
+	}
+
+	var data []byte
+	var err error
+	if path := fs.Arg(0); path == "" || path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("reading input: %w", err)
+	}
+	issues := ValidateScrape(data)
+
+	switch strings.ToLower(outputFormat) {
+	case "json":
+		if issues == nil {
+			issues = []ValidationIssue{}
+		}
+		b, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(out, string(b)); err != nil {
+			return err
+		}
+	default:
+		if _, err := io.WriteString(out, FormatValidationTerminal(issues)); err != nil {
+			return err
+		}
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("validation failed with %d problems", len(issues))
+	}
+	return nil
+}
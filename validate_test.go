@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateScrape(t *testing.T) {
+	data := []byte(`# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{code="200"} 1
+requests_total{code="500",code="501"} 1
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="1"} 1
+latency_seconds_bucket{le="+Inf"} 1
+latency_seconds_sum 1
+latency_seconds_count 1
+requests_total{code="200"} 2
+# TYPE requests_total gauge
+# HELP requests_total Requests.
+up 1
+up 1
+broken{ 1
+`)
+
+	issues := ValidateScrape(data)
+	var got []string
+	for _, i := range issues {
+		got = append(got, i.Check)
+	}
+	require.Equal(t, []string{
+		checkDuplicateLabel,
+		checkSplitFamily,
+		checkDuplicateSeries,
+		checkMetadataAfterSamples,
+		checkConflictingMetadata,
+		checkMetadataAfterSamples,
+		checkDuplicateMetadata,
+		checkDuplicateSeries,
+		checkSyntax,
+	}, got)
+
+	require.Equal(t, ValidationIssue{Line: 4, Check: checkDuplicateLabel, Message: `label "code" repeated in series of requests_total`}, issues[0])
+	require.Equal(t, ValidationIssue{Line: 10, Check: checkSplitFamily, RelatedLine: 4,
+		Message: "family requests_total continues after other families (previous block ended on line 4)"}, issues[1])
+	require.Equal(t, ValidationIssue{Line: 10, Check: checkDuplicateSeries, RelatedLine: 3,
+		Message: `series requests_total{code="200"} already exposed on line 3`}, issues[2])
+	require.Equal(t, ValidationIssue{Line: 11, Check: checkConflictingMetadata, RelatedLine: 2,
+		Message: "TYPE gauge for requests_total conflicts with TYPE counter on line 2"}, issues[4])
+	require.Equal(t, 14, issues[7].Line)
+	require.Equal(t, 15, issues[8].Line)
+
+	// Histogram samples with suffixes belong to their family
+	require.Empty(t, ValidateScrape([]byte("# TYPE a histogram\na_bucket{le=\"+Inf\"} 1\na_sum 1\na_count 1\n# TYPE b gauge\nb 1\n")))
+}
+
+func TestRunValidate(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runValidate([]string{"-o", "json"}, strings.NewReader("up 1\n"), &out))
+	require.JSONEq(t, "[]", out.String())
+
+	out.Reset()
+	err := runValidate([]string{"-o", "json"}, strings.NewReader("up 1\nup 1\n"), &out)
+	require.Error(t, err)
+	var issues []ValidationIssue
+	require.NoError(t, json.Unmarshal(out.Bytes(), &issues))
+	require.Equal(t, []ValidationIssue{{Line: 2, Check: checkDuplicateSeries, Message: "series up already exposed on line 1", RelatedLine: 1}}, issues)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Checks reported by AnalyzeValues.
+const (
+	checkNonFiniteGauge      = "non_finite_gauge"
+	checkNegativeCounter     = "negative_counter"
+	checkNonMonotonicBuckets = "non_monotonic_buckets"
+	checkInfBucketMismatch   = "inf_bucket_count_mismatch"
+	checkQuantileOutOfRange  = "quantile_out_of_range"
+	checkExplicitTimestamp   = "explicit_timestamp"
+)
+
+// maxValueFindingExamples is the number of example series kept per finding.
+const maxValueFindingExamples = 3
+
+// valueCheckMessages describes each check for reports.
+var valueCheckMessages = map[string]string{
+	checkNonFiniteGauge:      "gauge is NaN or ±Inf",
+	checkNegativeCounter:     "counter is negative",
+	checkNonMonotonicBuckets: "cumulative bucket counts decrease",
+	checkInfBucketMismatch:   "+Inf bucket is missing or differs from _count",
+	checkQuantileOutOfRange:  "quantile outside [0,1]",
+	checkExplicitTimestamp:   "sample has an explicit timestamp",
+}
+
+// ValueFinding reports the series of a metric family failing one value check.
+// Count is the number of affected series; Examples holds the first
+// maxValueFindingExamples of them.
+type ValueFinding struct {
+	Family   string   `json:"family"`
+	Check    string   `json:"check"`
+	Message  string   `json:"message"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+// AnalyzeValues checks the sample values of the given metric families for
+// NaN/±Inf gauges, negative counters, inconsistent histograms, summary
+// quantiles outside [0,1] and explicit timestamps. Findings are sorted by
+// family, then check.
+func AnalyzeValues(mfs []*dto.MetricFamily) []ValueFinding {
+	var findings []ValueFinding
+	for _, mf := range mfs {
+		byCheck := make(map[string]*ValueFinding)
+		report := func(check, example string) {
+			f, ok := byCheck[check]
+			if !ok {
+				f = &ValueFinding{Family: mf.GetName(), Check: check, Message: valueCheckMessages[check]}
+				byCheck[check] = f
+			}
+			f.Count++
+			if len(f.Examples) < maxValueFindingExamples {
+				f.Examples = append(f.Examples, example)
+			}
+		}
+
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			series := formatSeries(name, m.Label)
+			if m.TimestampMs != nil {
+				report(checkExplicitTimestamp, fmt.Sprintf("%s @ %d", series, m.GetTimestampMs()))
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				if v := m.GetGauge().GetValue(); math.IsNaN(v) || math.IsInf(v, 0) {
+					report(checkNonFiniteGauge, series+" "+formatValue(v))
+				}
+			case dto.MetricType_COUNTER:
+				if v := m.GetCounter().GetValue(); v < 0 {
+					report(checkNegativeCounter, series+" "+formatValue(v))
+				}
+			case dto.MetricType_HISTOGRAM:
+				if detail, ok := checkBucketsMonotonic(m.GetHistogram()); !ok {
+					report(checkNonMonotonicBuckets, series+" "+detail)
+				}
+				if detail, ok := checkInfBucket(m.GetHistogram()); !ok {
+					report(checkInfBucketMismatch, series+" "+detail)
+				}
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					if v := q.GetQuantile(); math.IsNaN(v) || v < 0 || v > 1 {
+						report(checkQuantileOutOfRange, formatSeries(name, m.Label, "quantile", formatValue(v)))
+						break
+					}
+				}
+			}
+		}
+
+		for _, f := range byCheck {
+			findings = append(findings, *f)
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Family == findings[j].Family {
+			return findings[i].Check < findings[j].Check
+		}
+		return findings[i].Family < findings[j].Family
+	})
+	return findings
+}
+
+// bucketCount returns the cumulative count of a bucket, which is either an
+// integer or, for float histograms, a float.
+func bucketCount(b *dto.Bucket) float64 {
+	if b.CumulativeCountFloat != nil {
+		return b.GetCumulativeCountFloat()
+	}
+	return float64(b.GetCumulativeCount())
+}
+
+// sortedBuckets returns the buckets of h ordered by upper bound.
+func sortedBuckets(h *dto.Histogram) []*dto.Bucket {
+	buckets := append([]*dto.Bucket(nil), h.GetBucket()...)
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].GetUpperBound() < buckets[j].GetUpperBound()
+	})
+	return buckets
+}
+
+// checkBucketsMonotonic reports whether the cumulative counts of h never
+// decrease with increasing upper bound. If they do, the first offending pair
+// of buckets is described.
+func checkBucketsMonotonic(h *dto.Histogram) (string, bool) {
+	buckets := sortedBuckets(h)
+	for i := 1; i < len(buckets); i++ {
+		prev, cur := buckets[i-1], buckets[i]
+		if bucketCount(cur) < bucketCount(prev) {
+			return fmt.Sprintf(`le="%s" %s > le="%s" %s`,
+				formatValue(prev.GetUpperBound()), formatValue(bucketCount(prev)),
+				formatValue(cur.GetUpperBound()), formatValue(bucketCount(cur))), false
+		}
+	}
+	return "", true
+}
+
+// checkInfBucket reports whether h has a +Inf bucket matching its _count.
+func checkInfBucket(h *dto.Histogram) (string, bool) {
+	count := float64(h.GetSampleCount())
+	if h.SampleCountFloat != nil {
+		count = h.GetSampleCountFloat()
+	}
+	buckets := h.GetBucket()
+	if len(buckets) == 0 {
+		return "", true
+	}
+	for _, b := range buckets {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			if bucketCount(b) != count {
+				return fmt.Sprintf(`le="+Inf" %s != _count %s`, formatValue(bucketCount(b)), formatValue(count)), false
+			}
+			return "", true
+		}
+	}
+	return "no +Inf bucket", false
+}
+
+// formatSeries returns the series in exposition format notation, e.g.
+// `http_requests_total{code="200",method="get"}`. extra holds additional
+// label name/value pairs appended after the series' own labels.
+func formatSeries(name string, labels []*dto.LabelPair, extra ...string) string {
+	pairs := make([]string, 0, len(labels)+len(extra)/2)
+	for _, lp := range labels {
+		pairs = append(pairs, lp.GetName()+"="+strconv.Quote(lp.GetValue()))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+strconv.Quote(extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue formats a sample value like the text exposition format does.
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
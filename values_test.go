@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeValues(t *testing.T) {
+	data := []byte(`# TYPE temperature gauge
+temperature{room="a"} NaN
+temperature{room="b"} +Inf
+temperature{room="c"} 21.5
+# TYPE requests_total counter
+requests_total{code="200"} 10
+requests_total{code="500"} -3
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 5
+latency_seconds_bucket{le="1"} 4
+latency_seconds_bucket{le="+Inf"} 6
+latency_seconds_sum 2
+latency_seconds_count 7
+# TYPE size_bytes histogram
+size_bytes_bucket{le="10"} 1
+size_bytes_sum 2
+size_bytes_count 1
+# TYPE rpc_seconds summary
+rpc_seconds{quantile="0.5"} 1
+rpc_seconds{quantile="1.5"} 2
+rpc_seconds_sum 3
+rpc_seconds_count 2
+# TYPE queue_length gauge
+queue_length 3 1700000000000
+`)
+	mfs, err := decodeFamilies(data)
+	require.NoError(t, err)
+
+	require.Equal(t, []ValueFinding{
+		{Family: "latency_seconds", Check: checkInfBucketMismatch, Message: valueCheckMessages[checkInfBucketMismatch], Count: 1,
+			Examples: []string{`latency_seconds le="+Inf" 6 != _count 7`}},
+		{Family: "latency_seconds", Check: checkNonMonotonicBuckets, Message: valueCheckMessages[checkNonMonotonicBuckets], Count: 1,
+			Examples: []string{`latency_seconds le="0.1" 5 > le="1" 4`}},
+		{Family: "queue_length", Check: checkExplicitTimestamp, Message: valueCheckMessages[checkExplicitTimestamp], Count: 1,
+			Examples: []string{`queue_length @ 1700000000000`}},
+		{Family: "requests_total", Check: checkNegativeCounter, Message: valueCheckMessages[checkNegativeCounter], Count: 1,
+			Examples: []string{`requests_total{code="500"} -3`}},
+		{Family: "rpc_seconds", Check: checkQuantileOutOfRange, Message: valueCheckMessages[checkQuantileOutOfRange], Count: 1,
+			Examples: []string{`rpc_seconds{quantile="1.5"}`}},
+		{Family: "size_bytes", Check: checkInfBucketMismatch, Message: valueCheckMessages[checkInfBucketMismatch], Count: 1,
+			Examples: []string{`size_bytes no +Inf bucket`}},
+		{Family: "temperature", Check: checkNonFiniteGauge, Message: valueCheckMessages[checkNonFiniteGauge], Count: 2,
+			Examples: []string{`temperature{room="a"} NaN`, `temperature{room="b"} +Inf`}},
+	}, AnalyzeValues(mfs))
+
+	// A well-formed scrape has no findings
+	summary := SummarizeScrape([]byte("# TYPE up gauge\nup 1\n"))
+	require.Empty(t, summary.ValueFindings)
+}
@@ -0,0 +1,18 @@
+package main
+
+import "runtime/debug"
+
+// version is set at build time by goreleaser, see .goreleaser.yaml.
+var version = "dev"
+
+// toolVersion returns the release version, or the module version for builds
+// with `go install`.
+func toolVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}